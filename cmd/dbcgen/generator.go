@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strings"
+	"unicode"
+
+	"github.com/QuangTung97/dbc"
+)
+
+type tableDef struct {
+	name    string
+	columns []dbc.TableColumn
+}
+
+// generateCode returns the formatted Go source of the structs and the schemas of the tables
+func generateCode(packageName string, tables []tableDef) ([]byte, error) {
+	var body bytes.Buffer
+	imports := map[string]bool{}
+
+	for _, table := range tables {
+		if err := writeTable(&body, table, imports); err != nil {
+			return nil, err
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by dbcgen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package %s\n\n", packageName)
+	buf.WriteString("import (\n")
+	if imports["time"] {
+		buf.WriteString("\t\"time\"\n\n")
+	}
+	buf.WriteString("\t\"github.com/QuangTung97/dbc\"\n")
+	if imports["null"] {
+		buf.WriteString("\t\"github.com/QuangTung97/dbc/null\"\n")
+	}
+	buf.WriteString(")\n")
+	buf.Write(body.Bytes())
+
+	return format.Source(buf.Bytes())
+}
+
+func writeTable(buf *bytes.Buffer, table tableDef, imports map[string]bool) error {
+	if len(table.columns) == 0 {
+		return fmt.Errorf("table '%s' not found", table.name)
+	}
+
+	numPrimaryKeys := 0
+	for _, col := range table.columns {
+		if col.PrimaryKey {
+			numPrimaryKeys++
+		}
+	}
+	if numPrimaryKeys == 0 {
+		return fmt.Errorf("table '%s' has no primary key", table.name)
+	}
+
+	typeName := goName(table.name)
+
+	fieldTypes := make([]string, 0, len(table.columns))
+	for _, col := range table.columns {
+		baseType, err := goTypeOf(col.DataType)
+		if err != nil {
+			return fmt.Errorf("column '%s' in table '%s': %w", col.Name, table.name, err)
+		}
+		if baseType == "time.Time" {
+			imports["time"] = true
+		}
+
+		fieldType := baseType
+		if col.Nullable {
+			imports["null"] = true
+			fieldType = "null.Null[" + baseType + "]"
+		}
+		fieldTypes = append(fieldTypes, fieldType)
+	}
+
+	fmt.Fprintf(buf, "\n// ------------------------------\n\n")
+	fmt.Fprintf(buf, "type %s struct {\n", typeName)
+	for index, col := range table.columns {
+		fmt.Fprintf(buf, "\t%s %s `db:\"%s\"`\n", goName(col.Name), fieldTypes[index], col.Name)
+	}
+	buf.WriteString("}\n\n")
+
+	fmt.Fprintf(buf, "func (%s) TableName() string {\n\treturn %q\n}\n\n", typeName, table.name)
+
+	fmt.Fprintf(
+		buf, "var %sSchema = dbc.RegisterSchema(func(s *dbc.Schema[%s], table *%s) {\n",
+		typeName, typeName, typeName,
+	)
+	for index, col := range table.columns {
+		schemaFunc := schemaFuncOf(col, fieldTypes[index], numPrimaryKeys)
+		fmt.Fprintf(buf, "\tdbc.%s(s, &table.%s)\n", schemaFunc, goName(col.Name))
+	}
+	buf.WriteString("})\n")
+	return nil
+}
+
+func schemaFuncOf(col dbc.TableColumn, fieldType string, numPrimaryKeys int) string {
+	if !col.PrimaryKey {
+		return "SchemaEditable"
+	}
+	if numPrimaryKeys > 1 || fieldType != "int64" {
+		return "SchemaCompositePrimaryKey"
+	}
+	if col.AutoIncrement {
+		return "SchemaIDAutoInc"
+	}
+	return "SchemaIDInt64"
+}
+
+// goTypeOf maps the column type reported by MySQL, Postgres or SQLite to a Go type.
+// The checks follow the type affinity rules of SQLite, which also match the type names of MySQL and Postgres
+func goTypeOf(dataType string) (string, error) {
+	typ := strings.ToLower(strings.TrimSpace(dataType))
+
+	switch {
+	case typ == "":
+		// no type means BLOB affinity on SQLite
+		return "[]byte", nil
+	case strings.HasPrefix(typ, "tinyint(1)") || strings.Contains(typ, "bool"):
+		return "bool", nil
+	case strings.Contains(typ, "interval") || strings.Contains(typ, "point"):
+		return "string", nil
+	case strings.Contains(typ, "int"):
+		return "int64", nil
+	case strings.Contains(typ, "char") || strings.Contains(typ, "text") || strings.Contains(typ, "clob"):
+		return "string", nil
+	case strings.Contains(typ, "uuid") || strings.Contains(typ, "json"):
+		return "string", nil
+	case strings.HasPrefix(typ, "enum") || strings.HasPrefix(typ, "set("):
+		return "string", nil
+	case strings.Contains(typ, "blob") || strings.Contains(typ, "binary") || strings.Contains(typ, "bytea"):
+		return "[]byte", nil
+	case strings.Contains(typ, "real") || strings.Contains(typ, "floa") || strings.Contains(typ, "doub"):
+		return "float64", nil
+	case strings.Contains(typ, "date") || strings.Contains(typ, "time"):
+		return "time.Time", nil
+	case strings.Contains(typ, "dec") || strings.Contains(typ, "numeric"):
+		// keep the exact value of fixed-point numbers
+		return "string", nil
+	default:
+		return "", fmt.Errorf("unsupported column type '%s'", dataType)
+	}
+}
+
+// commonInitialisms are written in upper case, following the Go naming convention
+var commonInitialisms = map[string]bool{
+	"id": true, "ip": true, "url": true, "uri": true, "uuid": true,
+	"api": true, "http": true, "json": true, "sql": true, "html": true,
+}
+
+// goName converts a snake case name of a table or column to an exported Go identifier
+func goName(name string) string {
+	var buf strings.Builder
+	for _, word := range strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		lower := strings.ToLower(word)
+		if commonInitialisms[lower] {
+			buf.WriteString(strings.ToUpper(lower))
+			continue
+		}
+		runes := []rune(word)
+		runes[0] = unicode.ToUpper(runes[0])
+		buf.WriteString(string(runes))
+	}
+
+	result := buf.String()
+	if len(result) == 0 || !unicode.IsLetter([]rune(result)[0]) {
+		result = "X" + result
+	}
+	return result
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGoTypeOf(t *testing.T) {
+	for _, tc := range []struct {
+		dataType string
+		goType   string
+	}{
+		{dataType: "INTEGER", goType: "int64"},
+		{dataType: "bigint unsigned", goType: "int64"},
+		{dataType: "tinyint(1)", goType: "bool"},
+		{dataType: "boolean", goType: "bool"},
+		{dataType: "varchar(255)", goType: "string"},
+		{dataType: "character varying", goType: "string"},
+		{dataType: "jsonb", goType: "string"},
+		{dataType: "enum('a','b')", goType: "string"},
+		{dataType: "interval", goType: "string"},
+		{dataType: "bytea", goType: "[]byte"},
+		{dataType: "varbinary(16)", goType: "[]byte"},
+		{dataType: "", goType: "[]byte"},
+		{dataType: "double precision", goType: "float64"},
+		{dataType: "timestamp with time zone", goType: "time.Time"},
+		{dataType: "datetime(6)", goType: "time.Time"},
+		{dataType: "decimal(10,2)", goType: "string"},
+		{dataType: "numeric", goType: "string"},
+	} {
+		t.Run(tc.dataType, func(t *testing.T) {
+			goType, err := goTypeOf(tc.dataType)
+			assert.Equal(t, nil, err)
+			assert.Equal(t, tc.goType, goType)
+		})
+	}
+
+	_, err := goTypeOf("geometry")
+	assert.Equal(t, errors.New("unsupported column type 'geometry'"), err)
+}
+
+func TestGoName(t *testing.T) {
+	assert.Equal(t, "AuthUser", goName("auth_user"))
+	assert.Equal(t, "UserID", goName("user_id"))
+	assert.Equal(t, "AvatarURL", goName("avatar_url"))
+	assert.Equal(t, "OrderItems2", goName("order_items2"))
+	assert.Equal(t, "X2fa", goName("2fa"))
+	assert.Equal(t, "CreatedAt", goName("CreatedAt"))
+}
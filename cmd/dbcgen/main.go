@@ -0,0 +1,138 @@
+// Command dbcgen generates the Go structs and the dbc schemas of existing tables.
+//
+// Usage:
+//
+//	dbcgen -driver sqlite3 -dsn ./app.db -package models -out ./models/tables.go [table ...]
+//
+// Without table arguments, all tables are generated except the tables of the package dbmigrate.
+// Nullable columns use null.Null[F], and the primary key is defined with SchemaIDAutoInc, SchemaIDInt64
+// or SchemaCompositePrimaryKey depending on the keys of the table. Other columns are SchemaEditable.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/QuangTung97/dbc"
+)
+
+func main() {
+	if err := run(context.Background(), os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "dbcgen:", err)
+		os.Exit(1)
+	}
+}
+
+// dialects maps the names of the database/sql drivers to the dialects
+var dialects = map[string]dbc.DatabaseDialect{
+	"sqlite3":  dbc.DialectSqlite,
+	"mysql":    dbc.DialectMysql,
+	"postgres": dbc.DialectPostgres,
+}
+
+const usage = `usage: dbcgen [flags] [table ...]
+
+flags:
+`
+
+type config struct {
+	driver      string
+	dsn         string
+	packageName string
+	out         string
+}
+
+func run(ctx context.Context, args []string, stdout io.Writer) error {
+	var conf config
+
+	flags := flag.NewFlagSet("dbcgen", flag.ContinueOnError)
+	flags.SetOutput(stdout)
+	flags.StringVar(&conf.driver, "driver", "sqlite3", "database driver: sqlite3, mysql or postgres")
+	flags.StringVar(&conf.dsn, "dsn", "", "data source name of the database")
+	flags.StringVar(&conf.packageName, "package", "models", "package name of the generated file")
+	flags.StringVar(&conf.out, "out", "", "output file, the generated code is printed to stdout if empty")
+	flags.Usage = func() {
+		_, _ = fmt.Fprint(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	dialect, ok := dialects[conf.driver]
+	if !ok {
+		return fmt.Errorf("unsupported driver '%s'", conf.driver)
+	}
+	if len(conf.dsn) == 0 {
+		return errors.New("missing flag -dsn")
+	}
+
+	db, err := sqlx.Connect(conf.driver, conf.dsn)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	tableNames := flags.Args()
+	if len(tableNames) == 0 {
+		tableNames, err = listTables(ctx, db, dialect)
+		if err != nil {
+			return err
+		}
+	}
+
+	tables := make([]tableDef, 0, len(tableNames))
+	for _, name := range tableNames {
+		columns, err := dbc.GetTableColumns(ctx, db, dialect, name)
+		if err != nil {
+			return err
+		}
+		tables = append(tables, tableDef{name: name, columns: columns})
+	}
+
+	code, err := generateCode(conf.packageName, tables)
+	if err != nil {
+		return err
+	}
+
+	if len(conf.out) == 0 {
+		_, err = stdout.Write(code)
+		return err
+	}
+	if err := os.WriteFile(conf.out, code, 0o644); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(stdout, "generated %s\n", conf.out)
+	return nil
+}
+
+// listTables returns all tables, except the tables created by the package dbmigrate
+func listTables(ctx context.Context, db *sqlx.DB, dialect dbc.DatabaseDialect) ([]string, error) {
+	names, err := dbc.GetTableNames(ctx, db, dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]string, 0, len(names))
+	for _, name := range names {
+		if name == "schema_migration" || strings.HasPrefix(name, "schema_migration_") {
+			continue
+		}
+		result = append(result, name)
+	}
+	return result, nil
+}
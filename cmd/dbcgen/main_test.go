@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+func newGenTestDB(t *testing.T, queries ...string) string {
+	dsn := filepath.Join(t.TempDir(), "test.db")
+	db := sqlx.MustConnect("sqlite3", dsn)
+	defer func() { _ = db.Close() }()
+
+	for _, query := range queries {
+		_, err := db.Exec(query)
+		assert.Equal(t, nil, err)
+	}
+	return dsn
+}
+
+const authUserTable = `
+CREATE TABLE auth_user (
+    id INTEGER PRIMARY KEY,
+    username TEXT NOT NULL,
+    avatar_url TEXT,
+    created_at DATETIME NOT NULL
+)`
+
+const userRoleTable = `
+CREATE TABLE user_role (
+    user_id INTEGER NOT NULL,
+    role_id INTEGER NOT NULL,
+    enabled BOOLEAN,
+    PRIMARY KEY (user_id, role_id)
+)`
+
+const migrationTable = `CREATE TABLE schema_migration (id INTEGER PRIMARY KEY)`
+
+func TestGen(t *testing.T) {
+	dsn := newGenTestDB(t, authUserTable, userRoleTable, migrationTable)
+
+	var stdout bytes.Buffer
+	err := run(context.Background(), []string{"-dsn", dsn, "-package", "entity"}, &stdout)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, `// Code generated by dbcgen. DO NOT EDIT.
+
+package entity
+
+import (
+	"time"
+
+	"github.com/QuangTung97/dbc"
+	"github.com/QuangTung97/dbc/null"
+)
+
+// ------------------------------
+
+type AuthUser struct {
+	ID        int64             `+"`db:\"id\"`"+`
+	Username  string            `+"`db:\"username\"`"+`
+	AvatarURL null.Null[string] `+"`db:\"avatar_url\"`"+`
+	CreatedAt time.Time         `+"`db:\"created_at\"`"+`
+}
+
+func (AuthUser) TableName() string {
+	return "auth_user"
+}
+
+var AuthUserSchema = dbc.RegisterSchema(func(s *dbc.Schema[AuthUser], table *AuthUser) {
+	dbc.SchemaIDAutoInc(s, &table.ID)
+	dbc.SchemaEditable(s, &table.Username)
+	dbc.SchemaEditable(s, &table.AvatarURL)
+	dbc.SchemaEditable(s, &table.CreatedAt)
+})
+
+// ------------------------------
+
+type UserRole struct {
+	UserID  int64           `+"`db:\"user_id\"`"+`
+	RoleID  int64           `+"`db:\"role_id\"`"+`
+	Enabled null.Null[bool] `+"`db:\"enabled\"`"+`
+}
+
+func (UserRole) TableName() string {
+	return "user_role"
+}
+
+var UserRoleSchema = dbc.RegisterSchema(func(s *dbc.Schema[UserRole], table *UserRole) {
+	dbc.SchemaCompositePrimaryKey(s, &table.UserID)
+	dbc.SchemaCompositePrimaryKey(s, &table.RoleID)
+	dbc.SchemaEditable(s, &table.Enabled)
+})
+`, stdout.String())
+}
+
+func TestGen__Selected_Table_To_File(t *testing.T) {
+	dsn := newGenTestDB(t, authUserTable, userRoleTable,
+		`CREATE TABLE product (code TEXT NOT NULL PRIMARY KEY, price DECIMAL(10, 2) NOT NULL, image BLOB NOT NULL)`,
+	)
+	outFile := filepath.Join(t.TempDir(), "tables.go")
+
+	var stdout bytes.Buffer
+	err := run(context.Background(), []string{"-dsn", dsn, "-out", outFile, "product"}, &stdout)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "generated "+outFile+"\n", stdout.String())
+
+	code, err := os.ReadFile(outFile)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, `// Code generated by dbcgen. DO NOT EDIT.
+
+package models
+
+import (
+	"github.com/QuangTung97/dbc"
+)
+
+// ------------------------------
+
+type Product struct {
+	Code  string `+"`db:\"code\"`"+`
+	Price string `+"`db:\"price\"`"+`
+	Image []byte `+"`db:\"image\"`"+`
+}
+
+func (Product) TableName() string {
+	return "product"
+}
+
+var ProductSchema = dbc.RegisterSchema(func(s *dbc.Schema[Product], table *Product) {
+	dbc.SchemaCompositePrimaryKey(s, &table.Code)
+	dbc.SchemaEditable(s, &table.Price)
+	dbc.SchemaEditable(s, &table.Image)
+})
+`, string(code))
+}
+
+func TestGen__Errors(t *testing.T) {
+	dsn := newGenTestDB(t, `CREATE TABLE event_log (content TEXT NOT NULL)`)
+	ctx := context.Background()
+	var stdout bytes.Buffer
+
+	err := run(ctx, []string{"-dsn", dsn, "event_log"}, &stdout)
+	assert.Equal(t, errors.New("table 'event_log' has no primary key"), err)
+
+	err = run(ctx, []string{"-dsn", dsn, "not_found"}, &stdout)
+	assert.Equal(t, errors.New("table 'not_found' not found"), err)
+
+	err = run(ctx, []string{"-driver", "oracle", "-dsn", dsn}, &stdout)
+	assert.Equal(t, errors.New("unsupported driver 'oracle'"), err)
+
+	err = run(ctx, []string{}, &stdout)
+	assert.Equal(t, errors.New("missing flag -dsn"), err)
+}
@@ -0,0 +1,326 @@
+// Command dbmigrate runs the migrations of the package dbmigrate from the command line.
+//
+// Usage:
+//
+//	dbmigrate -driver sqlite3 -dsn ./app.db -dir ./migrations <command> [arguments]
+//
+// The commands are:
+//
+//	up                 run all the migrations that have not been applied
+//	down N             revert the last N applied migrations
+//	to V               migrate up or down to the version V
+//	status             print the current version and the pending migrations
+//	force V            set the current version to V and clear the dirty state, without running any script
+//	create [-down] T   create the migration file for the next version with the title T
+//	plan               print the pending migrations with their contents, without running them
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/QuangTung97/dbc/dbmigrate"
+)
+
+func main() {
+	if err := run(context.Background(), os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "dbmigrate:", err)
+		os.Exit(1)
+	}
+}
+
+// databaseTypes maps the names of the database/sql drivers to the database types
+var databaseTypes = map[string]dbmigrate.DatabaseType{
+	"sqlite3":  dbmigrate.DatabaseSQLite3,
+	"mysql":    dbmigrate.DatabaseMySQL,
+	"postgres": dbmigrate.DatabasePostgres,
+}
+
+const usage = `usage: dbmigrate [flags] <command> [arguments]
+
+commands:
+  up                 run all the migrations that have not been applied
+  down N             revert the last N applied migrations
+  to V               migrate up or down to the version V
+  status             print the current version and the pending migrations
+  force V            set the current version to V and clear the dirty state
+  create [-down] T   create the migration file for the next version with the title T
+  plan               print the pending migrations with their contents
+
+flags:
+`
+
+type config struct {
+	driver      string
+	dsn         string
+	dir         string
+	lockTimeout time.Duration
+	versionGaps bool
+	outOfOrder  bool
+}
+
+func run(ctx context.Context, args []string, stdout io.Writer) error {
+	var conf config
+
+	flags := flag.NewFlagSet("dbmigrate", flag.ContinueOnError)
+	flags.SetOutput(stdout)
+	flags.StringVar(&conf.driver, "driver", "sqlite3", "database driver: sqlite3, mysql or postgres")
+	flags.StringVar(&conf.dsn, "dsn", "", "data source name of the database")
+	flags.StringVar(&conf.dir, "dir", "migrations", "directory of the migration files")
+	flags.DurationVar(
+		&conf.lockTimeout, "lock-timeout", dbmigrate.DefaultLockTimeout,
+		"maximum duration to wait for the migration lock",
+	)
+	flags.BoolVar(
+		&conf.versionGaps, "version-gaps", false,
+		"allow gaps between versions, new migration files are created with timestamp versions",
+	)
+	flags.BoolVar(
+		&conf.outOfOrder, "out-of-order", false,
+		"allow applying versions lower than the last applied version, used with -version-gaps",
+	)
+	flags.Usage = func() {
+		_, _ = fmt.Fprint(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errors.New("missing command")
+	}
+
+	command := flags.Arg(0)
+	cmdArgs := flags.Args()[1:]
+
+	if command == "create" {
+		return runCreate(conf, cmdArgs, stdout, time.Now())
+	}
+
+	dbType, ok := databaseTypes[conf.driver]
+	if !ok {
+		return fmt.Errorf("unsupported driver '%s'", conf.driver)
+	}
+	if len(conf.dsn) == 0 {
+		return errors.New("missing flag -dsn")
+	}
+
+	db, err := sqlx.Connect(conf.driver, conf.dsn)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	options := []dbmigrate.MigrateOption{dbmigrate.WithLockTimeout(conf.lockTimeout)}
+	if conf.versionGaps {
+		options = append(options, dbmigrate.WithVersionGaps())
+	}
+	if conf.outOfOrder {
+		options = append(options, dbmigrate.WithOutOfOrder())
+	}
+
+	m := &migrator{
+		ctx:     ctx,
+		db:      db,
+		dbType:  dbType,
+		conf:    conf,
+		stdout:  stdout,
+		options: options,
+	}
+	return m.runCommand(command, cmdArgs)
+}
+
+type migrator struct {
+	ctx     context.Context
+	db      *sqlx.DB
+	dbType  dbmigrate.DatabaseType
+	conf    config
+	stdout  io.Writer
+	options []dbmigrate.MigrateOption
+}
+
+func (m *migrator) runCommand(command string, args []string) error {
+	fsys := os.DirFS(m.conf.dir)
+
+	switch command {
+	case "up":
+		if err := checkNumArgs(command, args, 0); err != nil {
+			return err
+		}
+		result, err := dbmigrate.MigrateUpContext(m.ctx, m.db, fsys, ".", m.dbType, m.options...)
+		m.printResult(result)
+		return err
+
+	case "down":
+		steps, err := parseIntArg(command, args)
+		if err != nil {
+			return err
+		}
+		result, err := dbmigrate.MigrateDownContext(m.ctx, m.db, fsys, ".", m.dbType, int(steps), m.options...)
+		m.printResult(result)
+		return err
+
+	case "to":
+		version, err := parseIntArg(command, args)
+		if err != nil {
+			return err
+		}
+		result, err := dbmigrate.MigrateToContext(m.ctx, m.db, fsys, ".", m.dbType, version, m.options...)
+		m.printResult(result)
+		return err
+
+	case "force":
+		version, err := parseIntArg(command, args)
+		if err != nil {
+			return err
+		}
+		if err := dbmigrate.ForceContext(m.ctx, m.db, fsys, ".", m.dbType, version, m.options...); err != nil {
+			return err
+		}
+		m.printf("forced version %04d\n", version)
+		return nil
+
+	case "status":
+		if err := checkNumArgs(command, args, 0); err != nil {
+			return err
+		}
+		status, err := dbmigrate.Status(m.ctx, m.db, fsys, ".", m.dbType, m.options...)
+		if err != nil {
+			return err
+		}
+		m.printStatus(status)
+		return nil
+
+	case "plan":
+		if err := checkNumArgs(command, args, 0); err != nil {
+			return err
+		}
+		plan, err := dbmigrate.Plan(m.ctx, m.db, fsys, ".", m.dbType, m.options...)
+		if err != nil {
+			return err
+		}
+		if len(plan.Pending) == 0 {
+			m.printf("no pending migration\n")
+		}
+		for _, pending := range plan.Pending {
+			m.printf("-- %s\n%s\n", pending.Filename, strings.TrimRight(string(pending.Content), "\n"))
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown command '%s'", command)
+	}
+}
+
+func (m *migrator) printf(format string, args ...any) {
+	_, _ = fmt.Fprintf(m.stdout, format, args...)
+}
+
+func (m *migrator) printStatus(status dbmigrate.MigrationStatus) {
+	m.printf("current version: %04d\n", status.CurrentVersion)
+	if status.IsDirty {
+		m.printf("dirty: %s\n", status.CurrentFilename)
+	}
+	m.printf("pending: %d\n", len(status.Pending))
+	for _, filename := range status.Pending {
+		m.printf("  %s\n", filename)
+	}
+	for _, version := range status.UnknownApplied {
+		m.printf("unknown applied version: %04d\n", version)
+	}
+}
+
+func (m *migrator) printResult(result dbmigrate.MigrateResult) {
+	for _, applied := range result.Applied {
+		m.printf("applied %s (%v)\n", applied.Filename, applied.Duration)
+	}
+}
+
+func checkNumArgs(command string, args []string, num int) error {
+	if len(args) != num {
+		return fmt.Errorf("command '%s' requires %d arguments, got %d", command, num, len(args))
+	}
+	return nil
+}
+
+func parseIntArg(command string, args []string) (int64, error) {
+	if err := checkNumArgs(command, args, 1); err != nil {
+		return 0, err
+	}
+	num, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number '%s' of command '%s'", args[0], command)
+	}
+	return num, nil
+}
+
+// timestampVersionLayout is the format of versions created with -version-gaps
+const timestampVersionLayout = "20060102150405"
+
+// runCreate creates the empty migration files, using the same numbering convention as the package dbmigrate
+func runCreate(conf config, args []string, stdout io.Writer, now time.Time) error {
+	dir := conf.dir
+
+	flags := flag.NewFlagSet("create", flag.ContinueOnError)
+	flags.SetOutput(stdout)
+	withDown := flags.Bool("down", false, "also create the down migration file")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return errors.New("command 'create' requires the title of the migration")
+	}
+	title := strings.Join(strings.Fields(flags.Arg(0)), "_")
+	if len(title) == 0 || strings.ContainsAny(title, `/\`) {
+		return fmt.Errorf("invalid migration title '%s'", flags.Arg(0))
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	var prefix string
+	if conf.versionGaps {
+		prefix = now.UTC().Format(timestampVersionLayout) + "_" + title
+	} else {
+		version, err := dbmigrate.NextMigrationVersion(os.DirFS(dir), ".")
+		if err != nil {
+			return err
+		}
+		prefix = fmt.Sprintf("%04d_%s", version, title)
+	}
+
+	filenames := []string{prefix + ".sql"}
+	if *withDown {
+		filenames = []string{prefix + ".up.sql", prefix + ".down.sql"}
+	}
+
+	for _, filename := range filenames {
+		fullPath := filepath.Join(dir, filename)
+		if err := os.WriteFile(fullPath, nil, 0o644); err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(stdout, "created %s\n", fullPath)
+	}
+	return nil
+}
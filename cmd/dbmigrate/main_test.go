@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type cliTest struct {
+	t   *testing.T
+	dir string
+	dsn string
+}
+
+func newCLITest(t *testing.T) *cliTest {
+	tempDir := t.TempDir()
+	return &cliTest{
+		t:   t,
+		dir: filepath.Join(tempDir, "migrations"),
+		dsn: filepath.Join(tempDir, "test.db"),
+	}
+}
+
+func (c *cliTest) run(args ...string) (string, error) {
+	var stdout bytes.Buffer
+	fullArgs := append([]string{"-driver", "sqlite3", "-dsn", c.dsn, "-dir", c.dir}, args...)
+	err := run(context.Background(), fullArgs, &stdout)
+	return stdout.String(), err
+}
+
+func (c *cliTest) writeFile(filename string, content string) {
+	err := os.WriteFile(filepath.Join(c.dir, filename), []byte(content), 0o644)
+	assert.Equal(c.t, nil, err)
+}
+
+// appliedFiles returns the filenames in the output of up / down / to, without the durations
+func appliedFiles(output string) []string {
+	var result []string
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "applied" {
+			result = append(result, fields[1])
+		}
+	}
+	return result
+}
+
+func TestCLI(t *testing.T) {
+	c := newCLITest(t)
+
+	output, err := c.run("create", "-down", "init users")
+	assert.Equal(t, nil, err)
+	assert.Equal(t,
+		"created "+filepath.Join(c.dir, "0001_init_users.up.sql")+"\n"+
+			"created "+filepath.Join(c.dir, "0001_init_users.down.sql")+"\n",
+		output,
+	)
+
+	output, err = c.run("create", "add_product")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "created "+filepath.Join(c.dir, "0002_add_product.sql")+"\n", output)
+
+	c.writeFile("0001_init_users.up.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);\n")
+	c.writeFile("0001_init_users.down.sql", "DROP TABLE users;\n")
+	c.writeFile("0002_add_product.sql", "CREATE TABLE product (id INTEGER PRIMARY KEY);\n")
+
+	// status before migrating
+	output, err = c.run("status")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "current version: 0000\npending: 2\n  0001_init_users.up.sql\n  0002_add_product.sql\n", output)
+
+	// plan
+	output, err = c.run("plan")
+	assert.Equal(t, nil, err)
+	assert.Equal(t,
+		"-- 0001_init_users.up.sql\nCREATE TABLE users (id INTEGER PRIMARY KEY);\n"+
+			"-- 0002_add_product.sql\nCREATE TABLE product (id INTEGER PRIMARY KEY);\n",
+		output,
+	)
+
+	// up to version 1
+	output, err = c.run("to", "1")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{"0001_init_users.up.sql"}, appliedFiles(output))
+
+	// up
+	output, err = c.run("up")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{"0002_add_product.sql"}, appliedFiles(output))
+
+	output, err = c.run("plan")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "no pending migration\n", output)
+
+	// down of version 2 is missing
+	output, err = c.run("down", "2")
+	assert.Equal(t, errors.New("missing down migration file of version '0002'"), err)
+	assert.Equal(t, "", output)
+
+	// force
+	output, err = c.run("force", "1")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "forced version 0001\n", output)
+
+	output, err = c.run("down", "1")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{"0001_init_users.down.sql"}, appliedFiles(output))
+
+	output, err = c.run("status")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "current version: 0000\npending: 2\n  0001_init_users.up.sql\n  0002_add_product.sql\n", output)
+}
+
+func TestCLI__Status_Dirty_And_Unknown(t *testing.T) {
+	c := newCLITest(t)
+
+	_, err := c.run("create", "init")
+	assert.Equal(t, nil, err)
+	_, err = c.run("create", "add_product")
+	assert.Equal(t, nil, err)
+
+	c.writeFile("0001_init.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);\n")
+	c.writeFile("0002_add_product.sql", "-- dbmigrate:no-transaction\nCREATE INDEX idx ON not_found (id);\n")
+
+	_, err = c.run("up")
+	assert.Equal(t, "migration file '0002_add_product.sql': no such table: main.not_found", err.Error())
+
+	output, err := c.run("status")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "current version: 0002\ndirty: 0002_add_product.sql\npending: 0\n", output)
+
+	// the file of the applied version is removed
+	c.writeFile("0002_add_product.sql", "CREATE TABLE product (id INTEGER PRIMARY KEY);\n")
+	_, err = c.run("force", "2")
+	assert.Equal(t, nil, err)
+	err = os.Remove(filepath.Join(c.dir, "0002_add_product.sql"))
+	assert.Equal(t, nil, err)
+
+	output, err = c.run("status")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "current version: 0002\npending: 0\nunknown applied version: 0002\n", output)
+}
+
+func TestCLI__Errors(t *testing.T) {
+	c := newCLITest(t)
+	err := os.MkdirAll(c.dir, 0o755)
+	assert.Equal(t, nil, err)
+
+	_, err = c.run()
+	assert.Equal(t, errors.New("missing command"), err)
+
+	_, err = c.run("unknown")
+	assert.Equal(t, errors.New("unknown command 'unknown'"), err)
+
+	_, err = c.run("down")
+	assert.Equal(t, errors.New("command 'down' requires 1 arguments, got 0"), err)
+
+	_, err = c.run("to", "abc")
+	assert.Equal(t, errors.New("invalid number 'abc' of command 'to'"), err)
+
+	_, err = c.run("create")
+	assert.Equal(t, errors.New("command 'create' requires the title of the migration"), err)
+
+	_, err = c.run("create", "a/b")
+	assert.Equal(t, errors.New("invalid migration title 'a/b'"), err)
+
+	var stdout bytes.Buffer
+	err = run(context.Background(), []string{"-driver", "oracle", "-dsn", "abc", "up"}, &stdout)
+	assert.Equal(t, errors.New("unsupported driver 'oracle'"), err)
+
+	err = run(context.Background(), []string{"up"}, &stdout)
+	assert.Equal(t, errors.New("missing flag -dsn"), err)
+}
+
+func TestCLI__Version_Gaps(t *testing.T) {
+	c := newCLITest(t)
+
+	now := time.Date(2026, 10, 19, 8, 30, 15, 0, time.UTC)
+	var stdout bytes.Buffer
+	err := runCreate(config{dir: c.dir, versionGaps: true}, []string{"init"}, &stdout, now)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "created "+filepath.Join(c.dir, "20261019083015_init.sql")+"\n", stdout.String())
+
+	c.writeFile("20261019083015_init.sql", "CREATE TABLE users (id INTEGER PRIMARY KEY);\n")
+	c.writeFile("20261020000000_add_product.sql", "CREATE TABLE product (id INTEGER PRIMARY KEY);\n")
+
+	output, err := c.run("-version-gaps", "to", "20261020000000")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{"20261019083015_init.sql", "20261020000000_add_product.sql"}, appliedFiles(output))
+
+	// a file created later on another branch, with a lower version
+	c.writeFile("20261019120000_add_order.sql", "CREATE TABLE orders (id INTEGER PRIMARY KEY);\n")
+
+	output, err = c.run("-version-gaps", "status")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "current version: 20261020000000\npending: 1\n  20261019120000_add_order.sql\n", output)
+
+	_, err = c.run("-version-gaps", "up")
+	assert.Equal(t, errors.New(
+		"migration file '20261019120000_add_order.sql' has a version lower than "+
+			"the last applied version '20261020000000', use WithOutOfOrder to apply it",
+	), err)
+
+	output, err = c.run("-version-gaps", "-out-of-order", "up")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{"20261019120000_add_order.sql"}, appliedFiles(output))
+}
@@ -23,11 +23,8 @@ func NewCondBuilder[T any](dialect DatabaseDialect) (*CondBuilder[T], *T) {
 	tablePtr := &emptyVal
 
 	offsetDBName := map[fieldOffsetType]string{}
-	tableType := reflect.TypeOf(emptyVal)
-	for index := range tableType.NumField() {
-		field := tableType.Field(index)
-		offset := fieldOffsetType(field.Offset)
-		offsetDBName[offset] = field.Tag.Get(DBTag)
+	for _, f := range collectStructFields(reflect.TypeOf(emptyVal)) {
+		offsetDBName[f.offset] = f.field.Tag.Get(DBTag)
 	}
 
 	return &CondBuilder[T]{
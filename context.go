@@ -5,8 +5,17 @@ import "context"
 var ctxKey = new(int)
 
 type contextValueType struct {
-	isReadonly bool
-	tx         Transaction
+	isReadonly    bool
+	isTransaction bool
+	tx            Transaction
+
+	provider Provider
+}
+
+// providerCtxKey is used for storing the value of each Provider separately,
+// so that multiple databases can be used inside the same context
+type providerCtxKey struct {
+	provider Provider
 }
 
 func getFromContext(ctx context.Context) (*contextValueType, bool) {
@@ -14,6 +23,17 @@ func getFromContext(ctx context.Context) (*contextValueType, bool) {
 	return val, ok
 }
 
+func getFromContextOf(ctx context.Context, provider Provider) (*contextValueType, bool) {
+	if provider == nil {
+		return getFromContext(ctx)
+	}
+	val, ok := ctx.Value(providerCtxKey{provider: provider}).(*contextValueType)
+	return val, ok
+}
+
 func setToContext(ctx context.Context, val *contextValueType) context.Context {
+	if val.provider != nil {
+		ctx = context.WithValue(ctx, providerCtxKey{provider: val.provider}, val)
+	}
 	return context.WithValue(ctx, ctxKey, val)
 }
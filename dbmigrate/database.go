@@ -1,7 +1,12 @@
 package dbmigrate
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"math"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 
@@ -10,9 +15,26 @@ import (
 
 type DatabaseType int
 
+// supportsTransactionalDDL returns true if DDL statements can be rolled back inside a transaction
+func supportsTransactionalDDL(dbType DatabaseType) bool {
+	switch dbType {
+	case DatabaseSQLite3, DatabasePostgres:
+		return true
+	default:
+		return false
+	}
+}
+
+// dbExecutor is implemented by both *sqlx.DB and *sqlx.Tx
+type dbExecutor interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	NamedExecContext(ctx context.Context, query string, arg any) (sql.Result, error)
+}
+
 const (
 	DatabaseSQLite3 DatabaseType = iota + 1
 	DatabaseMySQL
+	DatabasePostgres
 )
 
 const SQLiteCreateTableQuery = `
@@ -33,19 +55,61 @@ CREATE TABLE IF NOT EXISTS schema_migration (
 );
 `
 
-func createTableFunc(db *sqlx.DB, dbType DatabaseType) error {
-	var query string
+const SQLiteCreateHistoryTableQuery = `
+CREATE TABLE IF NOT EXISTS schema_migration_history (
+    version INTEGER NOT NULL PRIMARY KEY,
+    filename TEXT NOT NULL,
+    checksum TEXT NOT NULL,
+    applied_at TIMESTAMP NOT NULL
+);
+`
+
+const MySQLCreateHistoryTableQuery = `
+CREATE TABLE IF NOT EXISTS schema_migration_history (
+    version BIGINT NOT NULL PRIMARY KEY,
+    filename VARCHAR(1024) NOT NULL,
+    checksum VARCHAR(64) NOT NULL,
+    applied_at DATETIME(6) NOT NULL
+);
+`
+
+const PostgresCreateTableQuery = `
+CREATE TABLE IF NOT EXISTS schema_migration (
+    id INTEGER NOT NULL PRIMARY KEY,
+    version BIGINT NOT NULL,
+    filename TEXT NOT NULL,
+    is_dirty BOOLEAN NOT NULL
+);
+`
+
+const PostgresCreateHistoryTableQuery = `
+CREATE TABLE IF NOT EXISTS schema_migration_history (
+    version BIGINT NOT NULL PRIMARY KEY,
+    filename TEXT NOT NULL,
+    checksum TEXT NOT NULL,
+    applied_at TIMESTAMPTZ NOT NULL
+);
+`
+
+func createTableFunc(ctx context.Context, db dbExecutor, dbType DatabaseType) error {
+	var queries []string
 	switch dbType {
 	case DatabaseSQLite3:
-		query = SQLiteCreateTableQuery
+		queries = []string{SQLiteCreateTableQuery, SQLiteCreateHistoryTableQuery}
 	case DatabaseMySQL:
-		query = MySQLCreateTableQuery
+		queries = []string{MySQLCreateTableQuery, MySQLCreateHistoryTableQuery}
+	case DatabasePostgres:
+		queries = []string{PostgresCreateTableQuery, PostgresCreateHistoryTableQuery}
 	default:
 		return fmt.Errorf("unsupported database type: %v", dbType)
 	}
 
-	_, err := db.Exec(query)
-	return err
+	for _, query := range queries {
+		if _, err := db.ExecContext(ctx, query); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 const SQLite3UpsertRowQuery = `
@@ -72,7 +136,21 @@ ON DUPLICATE KEY UPDATE
 	is_dirty = new.is_dirty
 `
 
-func upsertRowFunc(db *sqlx.DB, dbType DatabaseType, row SchemaMigration) error {
+// PostgresUpsertRowQuery uses positional parameters, because the bind type of named queries
+// depends on the driver name, which is not always recognized by sqlx (e.g. when using pgx with a custom name)
+const PostgresUpsertRowQuery = `
+INSERT INTO schema_migration (
+    id, version, filename, is_dirty
+) VALUES (
+    $1, $2, $3, $4
+)
+ON CONFLICT (id) DO UPDATE SET
+	version = EXCLUDED.version,
+	filename = EXCLUDED.filename,
+	is_dirty = EXCLUDED.is_dirty
+`
+
+func upsertRowFunc(ctx context.Context, db dbExecutor, dbType DatabaseType, row SchemaMigration) error {
 	var query string
 
 	switch dbType {
@@ -80,21 +158,24 @@ func upsertRowFunc(db *sqlx.DB, dbType DatabaseType, row SchemaMigration) error
 		query = SQLite3UpsertRowQuery
 	case DatabaseMySQL:
 		query = MySQLUpsertRowQuery
+	case DatabasePostgres:
+		_, err := db.ExecContext(ctx, PostgresUpsertRowQuery, row.ID, row.Version, row.Filename, row.IsDirty)
+		return err
 	default:
 		return fmt.Errorf("unsupported database type: %v", dbType)
 	}
 
-	_, err := db.NamedExec(query, row)
+	_, err := db.NamedExecContext(ctx, query, row)
 	return err
 }
 
-func getMigrationRow(db *sqlx.DB) (null.Null[SchemaMigration], error) {
+func getMigrationRow(ctx context.Context, db *sqlx.DB) (null.Null[SchemaMigration], error) {
 	query := `
 SELECT id, version, filename, is_dirty FROM schema_migration
 WHERE id = 1
 `
 	var result []SchemaMigration
-	if err := db.Select(&result, query); err != nil {
+	if err := db.SelectContext(ctx, &result, query); err != nil {
 		return null.Null[SchemaMigration]{}, err
 	}
 	if len(result) == 0 {
@@ -102,3 +183,355 @@ WHERE id = 1
 	}
 	return null.New(result[0]), nil
 }
+
+const SQLite3UpsertHistoryQuery = `
+INSERT INTO schema_migration_history (
+    version, filename, checksum, applied_at
+) VALUES (
+    :version, :filename, :checksum, :applied_at
+)
+ON CONFLICT (version) DO UPDATE SET
+	filename = EXCLUDED.filename,
+	checksum = EXCLUDED.checksum,
+	applied_at = EXCLUDED.applied_at
+`
+
+const MySQLUpsertHistoryQuery = `
+INSERT INTO schema_migration_history (
+    version, filename, checksum, applied_at
+) VALUES (
+    :version, :filename, :checksum, :applied_at
+) AS new
+ON DUPLICATE KEY UPDATE
+    filename = new.filename,
+	checksum = new.checksum,
+	applied_at = new.applied_at
+`
+
+const PostgresUpsertHistoryQuery = `
+INSERT INTO schema_migration_history (
+    version, filename, checksum, applied_at
+) VALUES (
+    $1, $2, $3, $4
+)
+ON CONFLICT (version) DO UPDATE SET
+	filename = EXCLUDED.filename,
+	checksum = EXCLUDED.checksum,
+	applied_at = EXCLUDED.applied_at
+`
+
+func upsertHistoryFunc(ctx context.Context, db dbExecutor, dbType DatabaseType, row MigrationHistory) error {
+	var query string
+	row.AppliedAt = time.Now().UTC()
+
+	switch dbType {
+	case DatabaseSQLite3:
+		query = SQLite3UpsertHistoryQuery
+	case DatabaseMySQL:
+		query = MySQLUpsertHistoryQuery
+	case DatabasePostgres:
+		_, err := db.ExecContext(
+			ctx, PostgresUpsertHistoryQuery,
+			row.Version, row.Filename, row.Checksum, row.AppliedAt,
+		)
+		return err
+	default:
+		return fmt.Errorf("unsupported database type: %v", dbType)
+	}
+
+	_, err := db.NamedExecContext(ctx, query, row)
+	return err
+}
+
+const DeleteHistoryQuery = `DELETE FROM schema_migration_history WHERE version = ?`
+const PostgresDeleteHistoryQuery = `DELETE FROM schema_migration_history WHERE version = $1`
+
+const DeleteHistoryAfterQuery = `DELETE FROM schema_migration_history WHERE version > ?`
+const PostgresDeleteHistoryAfterQuery = `DELETE FROM schema_migration_history WHERE version > $1`
+
+func deleteHistoryFunc(ctx context.Context, db dbExecutor, dbType DatabaseType, version int64) error {
+	query := DeleteHistoryQuery
+	if dbType == DatabasePostgres {
+		query = PostgresDeleteHistoryQuery
+	}
+	_, err := db.ExecContext(ctx, query, version)
+	return err
+}
+
+func deleteHistoryAfterFunc(ctx context.Context, db dbExecutor, dbType DatabaseType, version int64) error {
+	query := DeleteHistoryAfterQuery
+	if dbType == DatabasePostgres {
+		query = PostgresDeleteHistoryAfterQuery
+	}
+	_, err := db.ExecContext(ctx, query, version)
+	return err
+}
+
+const SQLiteTableExistsQuery = `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`
+
+const MySQLTableExistsQuery = `
+SELECT COUNT(*) FROM information_schema.tables
+WHERE table_schema = DATABASE() AND table_name = ?
+`
+
+const PostgresTableExistsQuery = `
+SELECT COUNT(*) FROM information_schema.tables
+WHERE table_schema = current_schema() AND table_name = $1
+`
+
+func tableExistsFunc(ctx context.Context, db *sqlx.DB, dbType DatabaseType, table string) (bool, error) {
+	var query string
+	switch dbType {
+	case DatabaseSQLite3:
+		query = SQLiteTableExistsQuery
+	case DatabaseMySQL:
+		query = MySQLTableExistsQuery
+	case DatabasePostgres:
+		query = PostgresTableExistsQuery
+	default:
+		return false, fmt.Errorf("unsupported database type: %v", dbType)
+	}
+
+	var count int
+	if err := db.GetContext(ctx, &count, query, table); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// getMigrationHistory returns the history without applied_at, because scanning DATETIME into time.Time
+// requires parseTime=true in the DSN of go-sql-driver/mysql
+func getMigrationHistory(ctx context.Context, db *sqlx.DB) ([]MigrationHistory, error) {
+	query := `
+SELECT version, filename, checksum FROM schema_migration_history
+ORDER BY version
+`
+	var result []MigrationHistory
+	if err := db.SelectContext(ctx, &result, query); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func withTransaction(ctx context.Context, db *sqlx.DB, fn func(tx *sqlx.Tx) error) error {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// markAppliedFunc marks the migration row clean & stores the history in the same transaction
+func markAppliedFunc(
+	ctx context.Context, db *sqlx.DB, dbType DatabaseType,
+	row SchemaMigration, history MigrationHistory,
+) error {
+	return withTransaction(ctx, db, func(tx *sqlx.Tx) error {
+		if err := upsertRowFunc(ctx, tx, dbType, row); err != nil {
+			return err
+		}
+		return upsertHistoryFunc(ctx, tx, dbType, history)
+	})
+}
+
+// markRevertedFunc marks the migration row clean & deletes the history of the reverted version in the same transaction
+func markRevertedFunc(
+	ctx context.Context, db *sqlx.DB, dbType DatabaseType,
+	row SchemaMigration, revertedVersion int64,
+) error {
+	return withTransaction(ctx, db, func(tx *sqlx.Tx) error {
+		if err := upsertRowFunc(ctx, tx, dbType, row); err != nil {
+			return err
+		}
+		return deleteHistoryFunc(ctx, tx, dbType, revertedVersion)
+	})
+}
+
+func forceFunc(
+	ctx context.Context, db *sqlx.DB, dbType DatabaseType,
+	row SchemaMigration, history null.Null[MigrationHistory],
+) error {
+	return withTransaction(ctx, db, func(tx *sqlx.Tx) error {
+		if err := upsertRowFunc(ctx, tx, dbType, row); err != nil {
+			return err
+		}
+		if err := deleteHistoryAfterFunc(ctx, tx, dbType, row.Version); err != nil {
+			return err
+		}
+		if !history.Valid {
+			return nil
+		}
+		return upsertHistoryFunc(ctx, tx, dbType, history.Data)
+	})
+}
+
+// ErrLockTimeout is returned when the migration lock is still held by another process after the lock timeout
+var ErrLockTimeout = errors.New("dbmigrate: timeout acquiring the migration lock")
+
+// lockConn is implemented by *sqlx.Conn and *sqlx.DB
+type lockConn interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	GetContext(ctx context.Context, dest any, query string, args ...any) error
+}
+
+// lockPollInterval is the interval between attempts of acquiring the lock, for databases without a lock timeout
+const lockPollInterval = 100 * time.Millisecond
+
+// MySQLLockQuery acquires the named lock of the current database,
+// because the names of GET_LOCK are shared by all databases of the server
+const MySQLLockQuery = `SELECT GET_LOCK(CONCAT('schema_migration:', DATABASE()), ?)`
+const MySQLUnlockQuery = `SELECT RELEASE_LOCK(CONCAT('schema_migration:', DATABASE()))`
+
+// postgresLockID is the key of the advisory lock used by dbmigrate,
+// an arbitrary constant that is unlikely to collide with locks of applications
+const postgresLockID int64 = 0x2f0c5b1a6ee43d17
+
+const PostgresTryLockQuery = `SELECT pg_try_advisory_lock($1)`
+const PostgresUnlockQuery = `SELECT pg_advisory_unlock($1)`
+
+// SQLiteCreateLockTableQuery creates the lock table for SQLite, which does not have named locks.
+// BEGIN EXCLUSIVE is not used because it would also block the migration scripts running on other connections
+const SQLiteCreateLockTableQuery = `
+CREATE TABLE IF NOT EXISTS schema_migration_lock (
+    id INTEGER NOT NULL PRIMARY KEY,
+    locked_at TIMESTAMP NOT NULL
+);
+`
+
+// SQLiteStaleLockTimeout is the age after which the lock row of SQLite is taken over by another process,
+// because the row is left in the table when the process holding it crashed
+const SQLiteStaleLockTimeout = 30 * time.Minute
+
+// SQLiteTryLockQuery inserts the lock row, or takes over the row locked before the second parameter.
+// locked_at is always written in UTC, so its text values are ordered by time
+const SQLiteTryLockQuery = `
+INSERT INTO schema_migration_lock (id, locked_at) VALUES (1, ?)
+ON CONFLICT (id) DO UPDATE SET locked_at = excluded.locked_at
+WHERE schema_migration_lock.locked_at < ?
+`
+
+// SQLiteUnlockQuery deletes the lock row, unless it has been taken over by another process
+const SQLiteUnlockQuery = `DELETE FROM schema_migration_lock WHERE id = 1 AND locked_at = ?`
+
+// lockFunc acquires the database lock before reading the migration row, so only one process
+// can run migrations at a time, the others wait at most 'timeout' for the lock.
+// The returned function releases the lock
+func lockFunc(ctx context.Context, db *sqlx.DB, dbType DatabaseType, timeout time.Duration) (func(), error) {
+	if dbType == DatabaseSQLite3 {
+		// the lock of sqlite is a row, not owned by a connection. Holding a connection for the whole migration
+		// would block forever on a pool with only one connection, the usual setup of sqlite
+		return lockSQLite(ctx, db, timeout)
+	}
+
+	// the locks of mysql & postgres are held by a session, so the lock & unlock must be on the same connection
+	conn, err := db.Connx(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	unlock, err := lockOnConn(ctx, conn, dbType, timeout)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return func() {
+		unlock()
+		_ = conn.Close()
+	}, nil
+}
+
+func lockOnConn(ctx context.Context, conn lockConn, dbType DatabaseType, timeout time.Duration) (func(), error) {
+	switch dbType {
+	case DatabaseMySQL:
+		var result sql.NullInt64
+		seconds := int64(math.Ceil(timeout.Seconds()))
+		if err := conn.GetContext(ctx, &result, MySQLLockQuery, seconds); err != nil {
+			return nil, err
+		}
+		if !result.Valid || result.Int64 != 1 {
+			return nil, lockTimeoutError(timeout)
+		}
+		return func() {
+			_, _ = conn.ExecContext(context.Background(), MySQLUnlockQuery)
+		}, nil
+
+	case DatabasePostgres:
+		err := pollLock(ctx, timeout, func() (bool, error) {
+			var locked bool
+			err := conn.GetContext(ctx, &locked, PostgresTryLockQuery, postgresLockID)
+			return locked, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		return func() {
+			_, _ = conn.ExecContext(context.Background(), PostgresUnlockQuery, postgresLockID)
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported database type: %v", dbType)
+	}
+}
+
+// lockSQLite acquires the lock by inserting the row of table schema_migration_lock.
+// A row older than SQLiteStaleLockTimeout is considered left by a crashed process and is taken over
+func lockSQLite(ctx context.Context, db lockConn, timeout time.Duration) (func(), error) {
+	if _, err := db.ExecContext(ctx, SQLiteCreateLockTableQuery); err != nil {
+		return nil, err
+	}
+	var lockedAt time.Time
+	err := pollLock(ctx, timeout, func() (bool, error) {
+		lockedAt = time.Now().UTC()
+		staleBefore := lockedAt.Add(-SQLiteStaleLockTimeout)
+		result, err := db.ExecContext(ctx, SQLiteTryLockQuery, lockedAt, staleBefore)
+		if err != nil {
+			return false, err
+		}
+		affected, err := result.RowsAffected()
+		return affected == 1, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf(
+			"%w, if no other process is migrating, delete the row in table 'schema_migration_lock'"+
+				" or wait until it is older than %v", err, SQLiteStaleLockTimeout,
+		)
+	}
+	return func() {
+		_, _ = db.ExecContext(context.Background(), SQLiteUnlockQuery, lockedAt)
+	}, nil
+}
+
+// pollLock calls tryLock until it succeeds or the timeout has passed
+func pollLock(ctx context.Context, timeout time.Duration, tryLock func() (bool, error)) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		locked, err := tryLock()
+		if err != nil {
+			return err
+		}
+		if locked {
+			return nil
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return lockTimeoutError(timeout)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(min(lockPollInterval, remaining)):
+		}
+	}
+}
+
+func lockTimeoutError(timeout time.Duration) error {
+	return fmt.Errorf("%w after %v", ErrLockTimeout, timeout)
+}
@@ -0,0 +1,362 @@
+package dbmigrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/QuangTung97/dbc/null"
+)
+
+type execInput struct {
+	query string
+	args  []any
+}
+
+type fakeExecutor struct {
+	inputs []execInput
+
+	execResult sql.Result
+
+	// execErr is returned when executing the query execErrQuery
+	execErrQuery string
+	execErr      error
+
+	// getResults are assigned to the dest of GetContext in order
+	getResults []any
+}
+
+var _ dbExecutor = &fakeExecutor{}
+var _ lockConn = &fakeExecutor{}
+
+func (e *fakeExecutor) ExecContext(_ context.Context, query string, args ...any) (sql.Result, error) {
+	e.inputs = append(e.inputs, execInput{query: query, args: args})
+	if len(e.execErrQuery) > 0 && query == e.execErrQuery {
+		return nil, e.execErr
+	}
+	return e.execResult, nil
+}
+
+func (e *fakeExecutor) GetContext(_ context.Context, dest any, query string, args ...any) error {
+	e.inputs = append(e.inputs, execInput{query: query, args: args})
+
+	result := e.getResults[0]
+	e.getResults = e.getResults[1:]
+
+	reflect.ValueOf(dest).Elem().Set(reflect.ValueOf(result))
+	return nil
+}
+
+func (e *fakeExecutor) NamedExecContext(_ context.Context, query string, arg any) (sql.Result, error) {
+	e.inputs = append(e.inputs, execInput{query: query, args: []any{arg}})
+	return nil, nil
+}
+
+func TestPostgresQueries(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("create table", func(t *testing.T) {
+		e := &fakeExecutor{}
+		err := createTableFunc(ctx, e, DatabasePostgres)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []execInput{
+			{query: PostgresCreateTableQuery},
+			{query: PostgresCreateHistoryTableQuery},
+		}, e.inputs)
+	})
+
+	t.Run("upsert row", func(t *testing.T) {
+		e := &fakeExecutor{}
+		err := upsertRowFunc(ctx, e, DatabasePostgres, SchemaMigration{
+			ID:       1,
+			Version:  3,
+			Filename: "0003_init.sql",
+			IsDirty:  true,
+		})
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []execInput{
+			{
+				query: PostgresUpsertRowQuery,
+				args:  []any{int64(1), int64(3), "0003_init.sql", true},
+			},
+		}, e.inputs)
+	})
+
+	t.Run("upsert history", func(t *testing.T) {
+		e := &fakeExecutor{}
+		err := upsertHistoryFunc(ctx, e, DatabasePostgres, MigrationHistory{
+			Version:  3,
+			Filename: "0003_init.sql",
+			Checksum: "abcd",
+		})
+		assert.Equal(t, nil, err)
+		assert.Equal(t, 1, len(e.inputs))
+		assert.Equal(t, PostgresUpsertHistoryQuery, e.inputs[0].query)
+		assert.Equal(t, []any{int64(3), "0003_init.sql", "abcd"}, e.inputs[0].args[:3])
+	})
+
+	t.Run("delete history", func(t *testing.T) {
+		e := &fakeExecutor{}
+		err := deleteHistoryFunc(ctx, e, DatabasePostgres, 3)
+		assert.Equal(t, nil, err)
+		err = deleteHistoryAfterFunc(ctx, e, DatabasePostgres, 2)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []execInput{
+			{query: PostgresDeleteHistoryQuery, args: []any{int64(3)}},
+			{query: PostgresDeleteHistoryAfterQuery, args: []any{int64(2)}},
+		}, e.inputs)
+	})
+
+	t.Run("transactional ddl", func(t *testing.T) {
+		assert.Equal(t, true, supportsTransactionalDDL(DatabasePostgres))
+		assert.Equal(t, false, supportsTransactionalDDL(DatabaseMySQL))
+	})
+}
+
+// TestPostgresQueries__SQLite_Stand_In runs the postgres queries on sqlite,
+// which also accepts the ON CONFLICT syntax and the $n parameters
+func TestPostgresQueries__SQLite_Stand_In(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	err := createTableFunc(ctx, db, DatabasePostgres)
+	assert.Equal(t, nil, err)
+
+	// create again
+	err = createTableFunc(ctx, db, DatabasePostgres)
+	assert.Equal(t, nil, err)
+
+	row := SchemaMigration{
+		ID:       1,
+		Version:  2,
+		Filename: "0002_users.sql",
+		IsDirty:  true,
+	}
+	err = upsertRowFunc(ctx, db, DatabasePostgres, row)
+	assert.Equal(t, nil, err)
+
+	row.IsDirty = false
+	err = upsertRowFunc(ctx, db, DatabasePostgres, row)
+	assert.Equal(t, nil, err)
+
+	migrationRow, err := getMigrationRow(ctx, db)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, null.New(row), migrationRow)
+
+	err = upsertHistoryFunc(ctx, db, DatabasePostgres, MigrationHistory{
+		Version:  2,
+		Filename: "0002_users.sql",
+		Checksum: "old",
+	})
+	assert.Equal(t, nil, err)
+
+	err = upsertHistoryFunc(ctx, db, DatabasePostgres, MigrationHistory{
+		Version:  2,
+		Filename: "0002_users.sql",
+		Checksum: "new",
+	})
+	assert.Equal(t, nil, err)
+
+	// the sqlite driver does not parse the TIMESTAMPTZ type, so applied_at is not selected
+	var checksums []string
+	err = db.SelectContext(ctx, &checksums, `SELECT checksum FROM schema_migration_history`)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{"new"}, checksums)
+
+	for version := int64(3); version <= 5; version++ {
+		err = upsertHistoryFunc(ctx, db, DatabasePostgres, MigrationHistory{
+			Version:  version,
+			Filename: fmt.Sprintf("%04d_users.sql", version),
+		})
+		assert.Equal(t, nil, err)
+	}
+
+	err = deleteHistoryFunc(ctx, db, DatabasePostgres, 3)
+	assert.Equal(t, nil, err)
+	err = deleteHistoryAfterFunc(ctx, db, DatabasePostgres, 4)
+	assert.Equal(t, nil, err)
+
+	var versions []int64
+	err = db.SelectContext(ctx, &versions, `SELECT version FROM schema_migration_history ORDER BY version`)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []int64{2, 4}, versions)
+}
+
+func TestLockOnConn(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("mysql", func(t *testing.T) {
+		e := &fakeExecutor{
+			getResults: []any{sql.NullInt64{Valid: true, Int64: 1}},
+		}
+		unlock, err := lockOnConn(ctx, e, DatabaseMySQL, 1500*time.Millisecond)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []execInput{
+			{query: MySQLLockQuery, args: []any{int64(2)}},
+		}, e.inputs)
+
+		unlock()
+		assert.Equal(t, []execInput{
+			{query: MySQLLockQuery, args: []any{int64(2)}},
+			{query: MySQLUnlockQuery},
+		}, e.inputs)
+	})
+
+	t.Run("mysql timeout", func(t *testing.T) {
+		e := &fakeExecutor{
+			getResults: []any{sql.NullInt64{Valid: true, Int64: 0}},
+		}
+		unlock, err := lockOnConn(ctx, e, DatabaseMySQL, 3*time.Second)
+		assert.Equal(t, true, errors.Is(err, ErrLockTimeout))
+		assert.Equal(t, "dbmigrate: timeout acquiring the migration lock after 3s", err.Error())
+		assert.Nil(t, unlock)
+	})
+
+	t.Run("postgres", func(t *testing.T) {
+		e := &fakeExecutor{
+			getResults: []any{false, true},
+		}
+		unlock, err := lockOnConn(ctx, e, DatabasePostgres, time.Second)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []execInput{
+			{query: PostgresTryLockQuery, args: []any{postgresLockID}},
+			{query: PostgresTryLockQuery, args: []any{postgresLockID}},
+		}, e.inputs)
+
+		unlock()
+		assert.Equal(t, execInput{
+			query: PostgresUnlockQuery, args: []any{postgresLockID},
+		}, e.inputs[2])
+	})
+
+	t.Run("postgres timeout", func(t *testing.T) {
+		e := &fakeExecutor{
+			getResults: []any{false},
+		}
+		unlock, err := lockOnConn(ctx, e, DatabasePostgres, 0)
+		assert.Equal(t, true, errors.Is(err, ErrLockTimeout))
+		assert.Nil(t, unlock)
+		assert.Equal(t, 1, len(e.inputs))
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		_, err := lockOnConn(ctx, &fakeExecutor{}, DatabaseType(0), 0)
+		assert.Equal(t, errors.New("unsupported database type: 0"), err)
+	})
+}
+
+func TestLockFunc__SQLite(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	unlock, err := lockFunc(ctx, db, DatabaseSQLite3, 0)
+	assert.Equal(t, nil, err)
+
+	// lock again
+	_, err = lockFunc(ctx, db, DatabaseSQLite3, 200*time.Millisecond)
+	assert.Equal(t, true, errors.Is(err, ErrLockTimeout))
+	assert.Equal(t,
+		"dbmigrate: timeout acquiring the migration lock after 200ms, "+
+			"if no other process is migrating, delete the row in table 'schema_migration_lock' "+
+			"or wait until it is older than 30m0s",
+		err.Error(),
+	)
+
+	// lock while waiting for the unlock
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		unlock()
+	}()
+
+	unlock2, err := lockFunc(ctx, db, DatabaseSQLite3, time.Second)
+	assert.Equal(t, nil, err)
+	unlock2()
+}
+
+func TestLockFunc__SQLite_Stale_Lock(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	// the lock row left by a crashed process
+	crashedUnlock, err := lockFunc(ctx, db, DatabaseSQLite3, 0)
+	assert.Equal(t, nil, err)
+
+	lockedAt := time.Now().UTC().Add(-SQLiteStaleLockTimeout - time.Minute)
+	_, err = db.Exec(`UPDATE schema_migration_lock SET locked_at = ?`, lockedAt)
+	assert.Equal(t, nil, err)
+
+	unlock, err := lockFunc(ctx, db, DatabaseSQLite3, 0)
+	assert.Equal(t, nil, err)
+
+	// the old holder does not release the lock taken over
+	crashedUnlock()
+	_, err = lockFunc(ctx, db, DatabaseSQLite3, 0)
+	assert.Equal(t, true, errors.Is(err, ErrLockTimeout))
+
+	unlock()
+	unlock, err = lockFunc(ctx, db, DatabaseSQLite3, 0)
+	assert.Equal(t, nil, err)
+	unlock()
+}
+
+func TestLockFunc__SQLite_Single_Connection(t *testing.T) {
+	db := newTestDB(t)
+	db.SetMaxOpenConns(1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := MigrateUpContext(ctx, db, migrate01Dir, "testdata/migrate01", DatabaseSQLite3)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(result.Applied))
+
+	assertTableExist(t, db, "product")
+}
+
+// TestGetMigrationHistory__Applied_At_Not_Scanned stores applied_at as bytes, the same as DATETIME values returned
+// by go-sql-driver/mysql without parseTime=true, which can not be scanned into time.Time
+func TestGetMigrationHistory__Applied_At_Not_Scanned(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	err := createTableFunc(ctx, db, DatabaseSQLite3)
+	assert.Equal(t, nil, err)
+
+	_, err = db.Exec(`
+INSERT INTO schema_migration_history (version, filename, checksum, applied_at)
+VALUES (1, '0001_init.sql', 'abcd', X'323032362D31302D3139')
+`)
+	assert.Equal(t, nil, err)
+
+	historyList, err := getMigrationHistory(ctx, db)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []MigrationHistory{
+		{Version: 1, Filename: "0001_init.sql", Checksum: "abcd"},
+	}, historyList)
+}
+
+func TestTableExistsFunc__SQLite(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	exists, err := tableExistsFunc(ctx, db, DatabaseSQLite3, "schema_migration")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, false, exists)
+
+	err = createTableFunc(ctx, db, DatabaseSQLite3)
+	assert.Equal(t, nil, err)
+
+	exists, err = tableExistsFunc(ctx, db, DatabaseSQLite3, "schema_migration")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, true, exists)
+
+	exists, err = tableExistsFunc(ctx, db, DatabaseSQLite3, "schema_migration_history")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, true, exists)
+}
@@ -2,15 +2,18 @@ package dbmigrate
 
 import (
 	"cmp"
-	"embed"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io/fs"
 	"log/slog"
-	"path/filepath"
+	"path"
 	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 
@@ -26,117 +29,954 @@ type SchemaMigration struct {
 	IsDirty  bool   `db:"is_dirty"`
 }
 
+// MigrationHistory stores the checksum of every applied migration file,
+// to detect changes of files that have already been applied
+type MigrationHistory struct {
+	Version   int64     `db:"version"`
+	Filename  string    `db:"filename"`
+	Checksum  string    `db:"checksum"`
+	AppliedAt time.Time `db:"applied_at"` // only written, it is not read back from the database
+}
+
+// MigrateResult contains the migration scripts that have been run successfully, in the order they were run
+type MigrateResult struct {
+	Applied []AppliedMigration
+}
+
+type AppliedMigration struct {
+	Version  int64
+	Filename string
+	IsDown   bool
+	Duration time.Duration
+}
+
+// MigratePlan contains the migration files that would be applied by MigrateUp
+type MigratePlan struct {
+	CurrentVersion int64
+	Pending        []PendingMigration
+}
+
+type PendingMigration struct {
+	Version  int64
+	Filename string
+	Content  []byte
+}
+
+// MigrationStatus describes the state of the database compared with the migration files
+type MigrationStatus struct {
+	CurrentVersion  int64
+	CurrentFilename string
+
+	// IsDirty is true when a migration failed in the middle, the file of the current version may be partially applied
+	IsDirty bool
+
+	// Pending contains the filenames of the versions after the current version
+	Pending []string
+
+	// UnknownApplied contains the applied versions that do not have any migration file,
+	// e.g. when the database has been migrated by a newer release
+	UnknownApplied []int64
+}
+
+// UpToDate returns true if all migrations have been applied successfully
+func (s MigrationStatus) UpToDate() bool {
+	return !s.IsDirty && len(s.Pending) == 0 && len(s.UnknownApplied) == 0
+}
+
+// MigrateUp is similar to MigrateUpContext, but panics on error
 func MigrateUp(
-	db *sqlx.DB, embedDir embed.FS, migrationDir string,
-	dbType DatabaseType,
+	db *sqlx.DB, fsys fs.FS, migrationDir string,
+	dbType DatabaseType, options ...MigrateOption,
 ) {
-	entries, err := embedDir.ReadDir(migrationDir)
+	_, err := MigrateUpContext(context.Background(), db, fsys, migrationDir, dbType, options...)
 	if err != nil {
 		panic(err)
 	}
+}
 
-	err = doMigrateUp(
-		entries,
-		func() error {
-			return createTableFunc(db, dbType)
+// MigrateDown is similar to MigrateDownContext, but panics on error
+func MigrateDown(
+	db *sqlx.DB, fsys fs.FS, migrationDir string,
+	dbType DatabaseType, steps int, options ...MigrateOption,
+) {
+	_, err := MigrateDownContext(context.Background(), db, fsys, migrationDir, dbType, steps, options...)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// MigrateTo is similar to MigrateToContext, but panics on error
+func MigrateTo(
+	db *sqlx.DB, fsys fs.FS, migrationDir string,
+	dbType DatabaseType, version int64, options ...MigrateOption,
+) {
+	_, err := MigrateToContext(context.Background(), db, fsys, migrationDir, dbType, version, options...)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Force is similar to ForceContext, but panics on error
+func Force(
+	db *sqlx.DB, fsys fs.FS, migrationDir string,
+	dbType DatabaseType, version int64, options ...MigrateOption,
+) {
+	err := ForceContext(context.Background(), db, fsys, migrationDir, dbType, version, options...)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// MigrateUpContext runs all the migration scripts that have not been applied.
+// The context is checked before running each script.
+// On error, the result still contains the scripts that have been run successfully
+func MigrateUpContext(
+	ctx context.Context, db *sqlx.DB, fsys fs.FS, migrationDir string,
+	dbType DatabaseType, options ...MigrateOption,
+) (MigrateResult, error) {
+	return runMigrate(
+		ctx, db, fsys, migrationDir, dbType, options,
+		func(entries []fs.DirEntry, actions migrateActions) (MigrateResult, error) {
+			return doMigrateUp(ctx, entries, actions)
 		},
-		func() (null.Null[SchemaMigration], error) {
-			return getMigrationRow(db)
+	)
+}
+
+// MigrateDownContext runs the down scripts of the last 'steps' applied migrations, in reverse order.
+// Every one of those migrations must have a file with the suffix '.down.sql'
+func MigrateDownContext(
+	ctx context.Context, db *sqlx.DB, fsys fs.FS, migrationDir string,
+	dbType DatabaseType, steps int, options ...MigrateOption,
+) (MigrateResult, error) {
+	return runMigrate(
+		ctx, db, fsys, migrationDir, dbType, options,
+		func(entries []fs.DirEntry, actions migrateActions) (MigrateResult, error) {
+			return doMigrateDown(ctx, entries, actions, steps)
 		},
-		func(row SchemaMigration) error {
-			return upsertRowFunc(db, dbType, row)
+	)
+}
+
+// MigrateToContext migrates up or down to the specified version, version zero means reverting all migrations
+func MigrateToContext(
+	ctx context.Context, db *sqlx.DB, fsys fs.FS, migrationDir string,
+	dbType DatabaseType, version int64, options ...MigrateOption,
+) (MigrateResult, error) {
+	return runMigrate(
+		ctx, db, fsys, migrationDir, dbType, options,
+		func(entries []fs.DirEntry, actions migrateActions) (MigrateResult, error) {
+			return doMigrateTo(ctx, entries, actions, version)
 		},
-		func(filename string) error {
-			fullPath := filepath.Join(migrationDir, filename)
-			data, err := embedDir.ReadFile(fullPath)
-			if err != nil {
-				return err
-			}
+	)
+}
 
-			_, err = db.Exec(string(data))
-			return err
+// ForceContext sets the current version and clears the dirty state, without running any script.
+// It is used after manually repairing a failed migration.
+// The checksum of the file of the specified version is stored again, and the history of later versions is removed.
+// With WithVersionGaps, the history of other versions is kept and the highest applied version stays current
+func ForceContext(
+	ctx context.Context, db *sqlx.DB, fsys fs.FS, migrationDir string,
+	dbType DatabaseType, version int64, options ...MigrateOption,
+) error {
+	_, err := runMigrate(
+		ctx, db, fsys, migrationDir, dbType, options,
+		func(entries []fs.DirEntry, actions migrateActions) (MigrateResult, error) {
+			return MigrateResult{}, doForce(entries, actions, version)
 		},
 	)
+	return err
+}
+
+// Plan returns the migration files that have not been applied, with their contents, without running them.
+// The same validations as MigrateUpContext are performed. As a dry run, the migration lock is not acquired
+// and the migration tables are not created, if they do not exist all the files are pending
+func Plan(
+	ctx context.Context, db *sqlx.DB, fsys fs.FS, migrationDir string,
+	dbType DatabaseType, options ...MigrateOption,
+) (MigratePlan, error) {
+	entries, goFuncs, err := readMigrateEntries(fsys, migrationDir)
 	if err != nil {
-		panic(err)
+		return MigratePlan{}, err
+	}
+
+	opts := newMigrateOptions(options)
+	return doPlan(entries, newMigrateActions(ctx, db, fsys, migrationDir, dbType, goFuncs, opts))
+}
+
+// Status returns the current migration state without running any script, for health checks & dashboards.
+// Different from the migrate functions, a dirty state or unknown applied versions are reported instead of returning
+// errors, and the migration lock is not acquired. The migration tables are not created,
+// if they do not exist the status is version 0 with all the files pending
+func Status(
+	ctx context.Context, db *sqlx.DB, fsys fs.FS, migrationDir string,
+	dbType DatabaseType, options ...MigrateOption,
+) (MigrationStatus, error) {
+	entries, goFuncs, err := readMigrateEntries(fsys, migrationDir)
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+
+	opts := newMigrateOptions(options)
+	return doStatus(entries, newMigrateActions(ctx, db, fsys, migrationDir, dbType, goFuncs, opts))
+}
+
+// NextMigrationVersion returns the version number for a new migration file in the directory,
+// which is one plus the number of existing versions. The existing files must be valid
+func NextMigrationVersion(fsys fs.FS, migrationDir string) (int64, error) {
+	entries, err := fs.ReadDir(fsys, migrationDir)
+	if err != nil {
+		return 0, err
+	}
+	if len(entries) == 0 {
+		return 1, nil
+	}
+
+	files, err := parseMigrateEntries(entries, false)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(files)) + 1, nil
+}
+
+func runMigrate(
+	ctx context.Context, db *sqlx.DB, fsys fs.FS, migrationDir string,
+	dbType DatabaseType, options []MigrateOption,
+	migrateFn func(entries []fs.DirEntry, actions migrateActions) (MigrateResult, error),
+) (MigrateResult, error) {
+	entries, goFuncs, err := readMigrateEntries(fsys, migrationDir)
+	if err != nil {
+		return MigrateResult{}, err
+	}
+
+	opts := newMigrateOptions(options)
+
+	unlock, err := lockFunc(ctx, db, dbType, opts.lockTimeout)
+	if err != nil {
+		return MigrateResult{}, err
+	}
+	defer unlock()
+
+	return migrateFn(entries, newMigrateActions(ctx, db, fsys, migrationDir, dbType, goFuncs, opts))
+}
+
+// readMigrateEntries returns the entries of the migration files
+// together with the Go migrations registered for the directory
+func readMigrateEntries(fsys fs.FS, migrationDir string) ([]fs.DirEntry, map[string]GoMigrationFunc, error) {
+	entries, err := fs.ReadDir(fsys, migrationDir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	goFuncs := getGoMigrations(migrationDir)
+	entries = appendGoMigrationEntries(entries, goFuncs)
+	return entries, goFuncs, nil
+}
+
+func newMigrateActions(
+	ctx context.Context, db *sqlx.DB, fsys fs.FS, migrationDir string,
+	dbType DatabaseType, goFuncs map[string]GoMigrationFunc, opts migrateOptions,
+) migrateActions {
+	// runInTx runs a migration file or a Go migration inside the transaction
+	runInTx := func(tx *sqlx.Tx, filename string) error {
+		if fn, ok := goFuncs[filename]; ok {
+			return fn(ctx, tx)
+		}
+		return runScriptFunc(ctx, tx, dbType, fsys, migrationDir, filename)
+	}
+
+	return migrateActions{
+		createTable: func() error {
+			return createTableFunc(ctx, db, dbType)
+		},
+		tableExists: func(table string) (bool, error) {
+			return tableExistsFunc(ctx, db, dbType, table)
+		},
+		getRow: func() (null.Null[SchemaMigration], error) {
+			return getMigrationRow(ctx, db)
+		},
+		upsertRow: func(row SchemaMigration) error {
+			return upsertRowFunc(ctx, db, dbType, row)
+		},
+		getHistory: func() ([]MigrationHistory, error) {
+			return getMigrationHistory(ctx, db)
+		},
+		markApplied: func(row SchemaMigration, history MigrationHistory) error {
+			return markAppliedFunc(ctx, db, dbType, row, history)
+		},
+		markReverted: func(row SchemaMigration, revertedVersion int64) error {
+			return markRevertedFunc(ctx, db, dbType, row, revertedVersion)
+		},
+		force: func(row SchemaMigration, history null.Null[MigrationHistory]) error {
+			return forceFunc(ctx, db, dbType, row, history)
+		},
+		readFile: func(filename string) ([]byte, error) {
+			if _, ok := goFuncs[filename]; ok {
+				return goMigrationContent(filename), nil
+			}
+			return fs.ReadFile(fsys, path.Join(migrationDir, filename))
+		},
+		runScript: func(filename string) error {
+			if _, ok := goFuncs[filename]; ok {
+				return withTransaction(ctx, db, func(tx *sqlx.Tx) error {
+					return runInTx(tx, filename)
+				})
+			}
+			return runScriptFunc(ctx, db, dbType, fsys, migrationDir, filename)
+		},
+
+		transactional: supportsTransactionalDDL(dbType),
+		applyInTx: func(filename string, row SchemaMigration, history MigrationHistory) error {
+			return withTransaction(ctx, db, func(tx *sqlx.Tx) error {
+				if err := runInTx(tx, filename); err != nil {
+					return err
+				}
+				if err := upsertRowFunc(ctx, tx, dbType, row); err != nil {
+					return err
+				}
+				return upsertHistoryFunc(ctx, tx, dbType, history)
+			})
+		},
+		revertInTx: func(filename string, row SchemaMigration, revertedVersion int64) error {
+			return withTransaction(ctx, db, func(tx *sqlx.Tx) error {
+				if err := runInTx(tx, filename); err != nil {
+					return err
+				}
+				if err := upsertRowFunc(ctx, tx, dbType, row); err != nil {
+					return err
+				}
+				return deleteHistoryFunc(ctx, tx, dbType, revertedVersion)
+			})
+		},
+
+		versionGaps: opts.versionGaps,
+		outOfOrder:  opts.outOfOrder,
+
+		now: time.Now,
 	}
 }
 
-func doMigrateUp(
-	entries []fs.DirEntry,
-	createTableFunc func() error,
-	getRowFunc func() (null.Null[SchemaMigration], error),
-	upsertRowFunc func(row SchemaMigration) error,
-	runScriptFunc func(filename string) error,
+// runScriptFunc runs the statements of a script one by one, errors contain the filename & the line of the statement.
+// For SQLite, the whole script is run at once because the driver supports multiple statements,
+// and the bodies of triggers contain semicolons without changing the delimiter
+func runScriptFunc(
+	ctx context.Context, db dbExecutor, dbType DatabaseType,
+	fsys fs.FS, migrationDir string, filename string,
 ) error {
+	fullPath := path.Join(migrationDir, filename)
+	data, err := fs.ReadFile(fsys, fullPath)
+	if err != nil {
+		return err
+	}
+
+	if dbType == DatabaseSQLite3 {
+		if _, err := db.ExecContext(ctx, string(data)); err != nil {
+			return fmt.Errorf("migration file '%s': %w", filename, err)
+		}
+		return nil
+	}
+
+	statements, err := splitStatements(string(data), dbType)
+	if err != nil {
+		return fmt.Errorf("migration file '%s': %w", filename, err)
+	}
+
+	for _, stmt := range statements {
+		if _, err := db.ExecContext(ctx, stmt.query); err != nil {
+			return fmt.Errorf("migration file '%s' at line %d: %w", filename, stmt.line, err)
+		}
+	}
+	return nil
+}
+
+type migrateActions struct {
+	createTable func() error
+	tableExists func(table string) (bool, error)
+	getRow      func() (null.Null[SchemaMigration], error)
+	upsertRow   func(row SchemaMigration) error
+
+	getHistory func() ([]MigrationHistory, error)
+
+	// markApplied & markReverted must update the row and the history atomically
+	markApplied  func(row SchemaMigration, history MigrationHistory) error
+	markReverted func(row SchemaMigration, revertedVersion int64) error
+	force        func(row SchemaMigration, history null.Null[MigrationHistory]) error
+
+	readFile  func(filename string) ([]byte, error)
+	runScript func(filename string) error
+
+	// transactional is true if the database supports transactional DDL.
+	// In that case, applyInTx & revertInTx run the script and update the row & the history in one transaction
+	transactional bool
+	applyInTx     func(filename string, row SchemaMigration, history MigrationHistory) error
+	revertInTx    func(filename string, row SchemaMigration, revertedVersion int64) error
+
+	// versionGaps & outOfOrder are set by WithVersionGaps & WithOutOfOrder
+	versionGaps bool
+	outOfOrder  bool
+
+	now func() time.Time
+}
+
+func parseMigrateEntries(entries []fs.DirEntry, versionGaps bool) ([]migrateFile, error) {
 	files := make([]migrateFile, 0, len(entries))
 	for _, entry := range entries {
 		file, err := parseMigrateFilename(entry.Name())
 		if err != nil {
-			return err
+			return nil, err
 		}
 		files = append(files, file)
 	}
 
-	if err := validateMigrateFiles(files); err != nil {
-		return err
+	files, err := groupMigrateFiles(files)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := createTableFunc(); err != nil {
-		return err
+	if err := validateMigrateFiles(files, versionGaps); err != nil {
+		return nil, err
 	}
+	return files, nil
+}
 
-	lastMigrateRow, err := getRowFunc()
+// prepareMigrate parses & validates the migration files, then returns them with the current version
+func prepareMigrate(entries []fs.DirEntry, actions migrateActions) ([]migrateFile, int64, error) {
+	files, err := parseMigrateEntries(entries, false)
 	if err != nil {
-		return err
+		return nil, 0, err
+	}
+
+	if err := actions.createTable(); err != nil {
+		return nil, 0, err
+	}
+
+	lastMigrateRow, err := actions.getRow()
+	if err != nil {
+		return nil, 0, err
 	}
 
+	var currentVersion int64
 	if lastMigrateRow.Valid {
-		lastVersion := lastMigrateRow.Data.Version
-		if lastVersion > int64(len(files)) {
-			return fmt.Errorf("not found version '%04d' in migration file list", lastVersion)
+		if err := checkDirtyRow(lastMigrateRow.Data); err != nil {
+			return nil, 0, err
+		}
+		currentVersion = lastMigrateRow.Data.Version
+		if currentVersion > int64(len(files)) {
+			return nil, 0, fmt.Errorf("not found version '%04d' in migration file list", currentVersion)
 		}
-		files = files[lastVersion:]
 	}
 
-	if len(files) == 0 {
+	historyList, err := actions.getHistory()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if err := verifyChecksums(files, historyList, actions); err != nil {
+		return nil, 0, err
+	}
+
+	return files, currentVersion, nil
+}
+
+func checkDirtyRow(row SchemaMigration) error {
+	if !row.IsDirty {
+		return nil
+	}
+	return fmt.Errorf(
+		"database is in dirty state at version '%04d' with file '%s', "+
+			"repair it manually then use Force to set the version",
+		row.Version, row.Filename,
+	)
+}
+
+// verifyChecksums compares the content of already applied files with the checksums stored in history table
+func verifyChecksums(files []migrateFile, historyList []MigrationHistory, actions migrateActions) error {
+	for _, history := range historyList {
+		file, ok := findFile(files, history.Version)
+		if !ok {
+			continue
+		}
+
+		checksum, err := computeChecksum(file.filename, actions)
+		if err != nil {
+			return err
+		}
+
+		if checksum != history.Checksum {
+			return fmt.Errorf(
+				"content of the applied migration file '%s' has changed, expected checksum '%s', got '%s'",
+				file.filename, history.Checksum, checksum,
+			)
+		}
+	}
+	return nil
+}
+
+func computeChecksum(filename string, actions migrateActions) (string, error) {
+	data, err := actions.readFile(filename)
+	if err != nil {
+		return "", err
+	}
+	return checksumOf(data), nil
+}
+
+func checksumOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+const noTransactionHeader = "-- dbmigrate:no-transaction"
+
+// hasNoTransactionHeader checks whether the header comment lines of a script contain noTransactionHeader
+func hasNoTransactionHeader(data []byte) bool {
+	for line := range strings.Lines(string(data)) {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		if !strings.HasPrefix(line, "--") {
+			return false
+		}
+		if line == noTransactionHeader {
+			return true
+		}
+	}
+	return false
+}
+
+func doMigrateUp(ctx context.Context, entries []fs.DirEntry, actions migrateActions) (MigrateResult, error) {
+	if actions.versionGaps {
+		return doMigrateUpWithGaps(ctx, entries, actions)
+	}
+
+	files, currentVersion, err := prepareMigrate(entries, actions)
+	if err != nil {
+		return MigrateResult{}, err
+	}
+	return runMigrateUp(ctx, files, currentVersion, int64(len(files)), actions)
+}
+
+func doPlan(entries []fs.DirEntry, actions migrateActions) (MigratePlan, error) {
+	var pending []migrateFile
+	var currentVersion int64
+
+	actions = readonlyActions(actions)
+
+	if actions.versionGaps {
+		state, err := prepareMigrateWithGaps(entries, actions)
+		if err != nil {
+			return MigratePlan{}, err
+		}
+		pending, currentVersion = state.pendingFiles(), state.lastApplied()
+	} else {
+		files, version, err := prepareMigrate(entries, actions)
+		if err != nil {
+			return MigratePlan{}, err
+		}
+		pending, currentVersion = files[version:], version
+	}
+
+	plan := MigratePlan{
+		CurrentVersion: currentVersion,
+	}
+	for _, file := range pending {
+		data, err := actions.readFile(file.filename)
+		if err != nil {
+			return MigratePlan{}, err
+		}
+		plan.Pending = append(plan.Pending, PendingMigration{
+			Version:  file.version,
+			Filename: file.filename,
+			Content:  data,
+		})
+	}
+	return plan, nil
+}
+
+func doStatus(entries []fs.DirEntry, actions migrateActions) (MigrationStatus, error) {
+	files, err := parseMigrateEntries(entries, actions.versionGaps)
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+
+	actions = readonlyActions(actions)
+
+	row, err := actions.getRow()
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+
+	historyList, err := actions.getHistory()
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+
+	var status MigrationStatus
+	if row.Valid {
+		status.CurrentVersion = row.Data.Version
+		status.CurrentFilename = row.Data.Filename
+		status.IsDirty = row.Data.IsDirty
+	}
+
+	if actions.versionGaps {
+		applied := map[int64]struct{}{}
+		for _, history := range historyList {
+			applied[history.Version] = struct{}{}
+		}
+		for _, file := range files {
+			if _, ok := applied[file.version]; !ok {
+				status.Pending = append(status.Pending, file.filename)
+			}
+		}
+	} else {
+		numFiles := int64(len(files))
+		for _, file := range files[min(status.CurrentVersion, numFiles):] {
+			status.Pending = append(status.Pending, file.filename)
+		}
+	}
+
+	unknownSet := map[int64]struct{}{}
+	for _, history := range historyList {
+		if _, ok := findFile(files, history.Version); !ok {
+			unknownSet[history.Version] = struct{}{}
+		}
+	}
+	if _, ok := findFile(files, status.CurrentVersion); !ok && status.CurrentVersion > 0 {
+		unknownSet[status.CurrentVersion] = struct{}{}
+	}
+	for version := range unknownSet {
+		status.UnknownApplied = append(status.UnknownApplied, version)
+	}
+	slices.Sort(status.UnknownApplied)
+
+	return status, nil
+}
+
+// readonlyActions does not create the migration tables, the missing tables are read as empty,
+// so that Status and Plan do not need the privileges for DDL
+func readonlyActions(actions migrateActions) migrateActions {
+	result := actions
+	result.createTable = func() error {
+		return nil
+	}
+	result.getRow = func() (null.Null[SchemaMigration], error) {
+		exists, err := actions.tableExists("schema_migration")
+		if err != nil || !exists {
+			return null.Null[SchemaMigration]{}, err
+		}
+		return actions.getRow()
+	}
+	result.getHistory = func() ([]MigrationHistory, error) {
+		exists, err := actions.tableExists("schema_migration_history")
+		if err != nil || !exists {
+			return nil, err
+		}
+		return actions.getHistory()
+	}
+	return result
+}
+
+func doMigrateDown(
+	ctx context.Context, entries []fs.DirEntry, actions migrateActions, steps int,
+) (MigrateResult, error) {
+	if steps <= 0 {
+		return MigrateResult{}, fmt.Errorf("number of steps to migrate down must be positive")
+	}
+
+	if actions.versionGaps {
+		return doMigrateDownWithGaps(ctx, entries, actions, steps)
+	}
+
+	files, currentVersion, err := prepareMigrate(entries, actions)
+	if err != nil {
+		return MigrateResult{}, err
+	}
+
+	if int64(steps) > currentVersion {
+		return MigrateResult{}, fmt.Errorf(
+			"can not migrate down %d steps from version '%04d'", steps, currentVersion,
+		)
+	}
+	return runMigrateDown(ctx, files, currentVersion, currentVersion-int64(steps), actions)
+}
+
+func doMigrateTo(
+	ctx context.Context, entries []fs.DirEntry, actions migrateActions, version int64,
+) (MigrateResult, error) {
+	if actions.versionGaps {
+		return doMigrateToWithGaps(ctx, entries, actions, version)
+	}
+
+	files, currentVersion, err := prepareMigrate(entries, actions)
+	if err != nil {
+		return MigrateResult{}, err
+	}
+
+	if version < 0 || version > int64(len(files)) {
+		return MigrateResult{}, fmt.Errorf("not found version '%04d' in migration file list", version)
+	}
+
+	if version < currentVersion {
+		return runMigrateDown(ctx, files, currentVersion, version, actions)
+	}
+	return runMigrateUp(ctx, files, currentVersion, version, actions)
+}
+
+func runMigrateUp(
+	ctx context.Context, files []migrateFile, currentVersion int64, targetVersion int64,
+	actions migrateActions,
+) (MigrateResult, error) {
+	var steps []migrateStep
+	for _, file := range files[currentVersion:targetVersion] {
+		steps = append(steps, migrateStep{
+			file:     file,
+			cleanRow: newCleanRow(files, file.version),
+		})
+	}
+	return runApplySteps(ctx, steps, actions)
+}
+
+// migrateStep is a migration file to apply or revert, with the migration row after it has been run successfully
+type migrateStep struct {
+	file     migrateFile
+	cleanRow SchemaMigration
+}
+
+func runApplySteps(ctx context.Context, steps []migrateStep, actions migrateActions) (MigrateResult, error) {
+	if len(steps) == 0 {
 		slog.Info("No migration is run")
 	}
 
-	for index, file := range files {
+	var result MigrateResult
+	for _, step := range steps {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		file := step.file
 		slog.Info("Run migration script", slog.String("script", file.filename))
 
-		row := SchemaMigration{
-			ID:       1,
+		startTime := actions.now()
+		if err := applyMigrateFile(file, step.cleanRow, actions); err != nil {
+			return result, err
+		}
+
+		result.Applied = append(result.Applied, AppliedMigration{
 			Version:  file.version,
 			Filename: file.filename,
-			IsDirty:  true,
+			Duration: actions.now().Sub(startTime),
+		})
+	}
+
+	return result, nil
+}
+
+func applyMigrateFile(file migrateFile, cleanRow SchemaMigration, actions migrateActions) error {
+	data, err := actions.readFile(file.filename)
+	if err != nil {
+		return err
+	}
+
+	history := MigrationHistory{
+		Version:  file.version,
+		Filename: file.filename,
+		Checksum: checksumOf(data),
+	}
+
+	if actions.transactional && !hasNoTransactionHeader(data) {
+		return actions.applyInTx(file.filename, cleanRow, history)
+	}
+
+	row := SchemaMigration{
+		ID:       1,
+		Version:  file.version,
+		Filename: file.filename,
+		IsDirty:  true,
+	}
+	if err := actions.upsertRow(row); err != nil {
+		return err
+	}
+
+	if err := actions.runScript(file.filename); err != nil {
+		return err
+	}
+
+	return actions.markApplied(cleanRow, history)
+}
+
+func runMigrateDown(
+	ctx context.Context, files []migrateFile, currentVersion int64, targetVersion int64,
+	actions migrateActions,
+) (MigrateResult, error) {
+	var steps []migrateStep
+	for version := currentVersion; version > targetVersion; version-- {
+		steps = append(steps, migrateStep{
+			file:     files[version-1],
+			cleanRow: newCleanRow(files, version-1),
+		})
+	}
+	return runRevertSteps(ctx, steps, actions)
+}
+
+// runRevertSteps runs the down scripts of the steps, in the order of the steps
+func runRevertSteps(ctx context.Context, steps []migrateStep, actions migrateActions) (MigrateResult, error) {
+	// check all down files exist before running any script
+	for _, step := range steps {
+		if len(step.file.downFilename) == 0 {
+			return MigrateResult{}, fmt.Errorf("missing down migration file of version '%04d'", step.file.version)
 		}
-		if err := upsertRowFunc(row); err != nil {
-			return err
+	}
+
+	if len(steps) == 0 {
+		slog.Info("No migration is run")
+		return MigrateResult{}, nil
+	}
+
+	var result MigrateResult
+	for _, step := range steps {
+		if err := ctx.Err(); err != nil {
+			return result, err
 		}
 
-		if err := runScriptFunc(file.filename); err != nil {
-			return err
+		file := step.file
+		slog.Info("Run migration script", slog.String("script", file.downFilename))
+
+		startTime := actions.now()
+		if err := revertMigrateFile(file, step.cleanRow, actions); err != nil {
+			return result, err
 		}
 
-		isLast := index >= len(files)-1
-		if isLast {
-			row.IsDirty = false
-			if err := upsertRowFunc(row); err != nil {
-				return err
-			}
+		result.Applied = append(result.Applied, AppliedMigration{
+			Version:  file.version,
+			Filename: file.downFilename,
+			IsDown:   true,
+			Duration: actions.now().Sub(startTime),
+		})
+	}
+
+	return result, nil
+}
+
+func revertMigrateFile(file migrateFile, cleanRow SchemaMigration, actions migrateActions) error {
+	data, err := actions.readFile(file.downFilename)
+	if err != nil {
+		return err
+	}
+
+	if actions.transactional && !hasNoTransactionHeader(data) {
+		return actions.revertInTx(file.downFilename, cleanRow, file.version)
+	}
+
+	row := SchemaMigration{
+		ID:       1,
+		Version:  file.version,
+		Filename: file.downFilename,
+		IsDirty:  true,
+	}
+	if err := actions.upsertRow(row); err != nil {
+		return err
+	}
+
+	if err := actions.runScript(file.downFilename); err != nil {
+		return err
+	}
+
+	return actions.markReverted(cleanRow, file.version)
+}
+
+// newCleanRow returns the migration row after the version has been applied, version zero means nothing applied
+func newCleanRow(files []migrateFile, version int64) SchemaMigration {
+	row := SchemaMigration{
+		ID:      1,
+		Version: version,
+		IsDirty: false,
+	}
+	if file, ok := findFile(files, version); ok {
+		row.Filename = file.filename
+	}
+	return row
+}
+
+// findFile finds the file of the version in the files sorted by version
+func findFile(files []migrateFile, version int64) (migrateFile, bool) {
+	index, found := slices.BinarySearchFunc(files, version, func(file migrateFile, version int64) int {
+		return cmp.Compare(file.version, version)
+	})
+	if !found {
+		return migrateFile{}, false
+	}
+	return files[index], true
+}
+
+func doForce(entries []fs.DirEntry, actions migrateActions, version int64) error {
+	files, err := parseMigrateEntries(entries, actions.versionGaps)
+	if err != nil {
+		return err
+	}
+
+	file, found := findFile(files, version)
+	if version != 0 && !found {
+		return fmt.Errorf("not found version '%04d' in migration file list", version)
+	}
+
+	if err := actions.createTable(); err != nil {
+		return err
+	}
+
+	var history null.Null[MigrationHistory]
+	if found {
+		checksum, err := computeChecksum(file.filename, actions)
+		if err != nil {
+			return err
 		}
+		history = null.New(MigrationHistory{
+			Version:  file.version,
+			Filename: file.filename,
+			Checksum: checksum,
+		})
 	}
 
-	return nil
+	slog.Info("Force migration version", slog.Int64("version", version))
+	if actions.versionGaps {
+		return forceWithGaps(files, actions, history)
+	}
+	return actions.force(newCleanRow(files, version), history)
 }
 
+// forceWithGaps marks the version applied without touching the history of other versions,
+// which may be applied after it out of order. The migration row keeps the highest applied version
+func forceWithGaps(files []migrateFile, actions migrateActions, history null.Null[MigrationHistory]) error {
+	historyList, err := actions.getHistory()
+	if err != nil {
+		return err
+	}
+
+	var lastVersion int64
+	if history.Valid {
+		lastVersion = history.Data.Version
+	}
+	for _, h := range historyList {
+		lastVersion = max(lastVersion, h.Version)
+	}
+
+	row := newCleanRow(files, lastVersion)
+	if !history.Valid {
+		return actions.upsertRow(row)
+	}
+	return actions.markApplied(row, history.Data)
+}
+
+// migrateFile is a migration version with its up & down files.
+// After parsing a single filename, only one of 'filename' or 'downFilename' is set
 type migrateFile struct {
-	version  int64
-	filename string
+	version      int64
+	filename     string
+	downFilename string
 }
 
+const (
+	upFileSuffix   = ".up.sql"
+	downFileSuffix = ".down.sql"
+)
+
 func parseMigrateFilename(filename string) (migrateFile, error) {
 	underscoreIndex := strings.Index(filename, "_")
 	if underscoreIndex <= 0 {
@@ -157,13 +997,59 @@ func parseMigrateFilename(filename string) (migrateFile, error) {
 		return migrateFile{}, fmt.Errorf("version number must start from 1")
 	}
 
+	if strings.HasSuffix(filename, downFileSuffix) {
+		return migrateFile{
+			version:      version,
+			downFilename: filename,
+		}, nil
+	}
+
 	return migrateFile{
 		version:  version,
 		filename: filename,
 	}, nil
 }
 
-func validateMigrateFiles(files []migrateFile) error {
+// groupMigrateFiles merges the up & down files of the same version
+func groupMigrateFiles(files []migrateFile) ([]migrateFile, error) {
+	result := make([]migrateFile, 0, len(files))
+	indexMap := map[int64]int{}
+
+	for _, file := range files {
+		index, existed := indexMap[file.version]
+		if !existed {
+			indexMap[file.version] = len(result)
+			result = append(result, file)
+			continue
+		}
+
+		prev := &result[index]
+		if len(file.filename) > 0 {
+			if len(prev.filename) > 0 {
+				return nil, fmt.Errorf("duplicated version number '%04d'", file.version)
+			}
+			prev.filename = file.filename
+		}
+		if len(file.downFilename) > 0 {
+			if len(prev.downFilename) > 0 {
+				return nil, fmt.Errorf("duplicated down migration of version '%04d'", file.version)
+			}
+			prev.downFilename = file.downFilename
+		}
+	}
+
+	for _, file := range result {
+		if len(file.filename) == 0 {
+			return nil, fmt.Errorf("missing up migration file of version '%04d'", file.version)
+		}
+	}
+
+	return result, nil
+}
+
+// validateMigrateFiles sorts the files by version and checks that the versions are 1..N,
+// or only checks for duplicated versions if versionGaps is true
+func validateMigrateFiles(files []migrateFile, versionGaps bool) error {
 	if len(files) == 0 {
 		return fmt.Errorf("migration file list must not be empty")
 	}
@@ -181,7 +1067,7 @@ func validateMigrateFiles(files []migrateFile) error {
 		}
 		existedNum[file.version] = struct{}{}
 
-		if file.version != int64(index)+1 {
+		if !versionGaps && file.version != int64(index)+1 {
 			return fmt.Errorf("missing version number '%04d'", index+1)
 		}
 	}
@@ -1,9 +1,13 @@
 package dbmigrate
 
 import (
+	"context"
 	"embed"
+	"os"
 	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	_ "github.com/mattn/go-sqlite3"
@@ -35,7 +39,7 @@ func TestMigrateUp__Integration(t *testing.T) {
 	assertTableExist(t, db, "auth_user")
 	assertTableExist(t, db, "product")
 
-	row, err := getMigrationRow(db)
+	row, err := getMigrationRow(context.Background(), db)
 	assert.Equal(t, nil, err)
 	assert.Equal(t, null.New(SchemaMigration{
 		ID:       1,
@@ -44,6 +48,18 @@ func TestMigrateUp__Integration(t *testing.T) {
 		IsDirty:  false,
 	}), row)
 
+	// check history
+	historyList, err := getMigrationHistory(context.Background(), db)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(historyList))
+	assert.Equal(t, "0002_add_product.sql", historyList[1].Filename)
+	assert.Equal(t, 64, len(historyList[1].Checksum))
+
+	var appliedCount int
+	err = db.Get(&appliedCount, `SELECT COUNT(*) FROM schema_migration_history WHERE applied_at IS NOT NULL`)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, appliedCount)
+
 	// migrate up again
 	MigrateUp(db, migrate01Dir, "testdata/migrate01", DatabaseSQLite3)
 }
@@ -57,3 +73,260 @@ func assertTableExist(t *testing.T, db *sqlx.DB, table string) {
 	assert.Equal(t, nil, err)
 	assert.Equal(t, 0, result)
 }
+
+//go:embed testdata/migrate02/*
+var migrate02Dir embed.FS
+
+func TestMigrateDown__Integration(t *testing.T) {
+	db := newTestDB(t)
+
+	MigrateUp(db, migrate02Dir, "testdata/migrate02", DatabaseSQLite3)
+	assertTableExist(t, db, "auth_user")
+	assertTableExist(t, db, "product")
+
+	// migrate down
+	MigrateDown(db, migrate02Dir, "testdata/migrate02", DatabaseSQLite3, 1)
+	assertTableExist(t, db, "auth_user")
+	assertTableNotExist(t, db, "product")
+
+	row, err := getMigrationRow(context.Background(), db)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, null.New(SchemaMigration{
+		ID:       1,
+		Version:  1,
+		Filename: "0001_init.up.sql",
+		IsDirty:  false,
+	}), row)
+
+	historyList, err := getMigrationHistory(context.Background(), db)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(historyList))
+
+	// migrate to version 0
+	MigrateTo(db, migrate02Dir, "testdata/migrate02", DatabaseSQLite3, 0)
+	assertTableNotExist(t, db, "auth_user")
+
+	row, err = getMigrationRow(context.Background(), db)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, null.New(SchemaMigration{ID: 1}), row)
+
+	// migrate to version 2
+	MigrateTo(db, migrate02Dir, "testdata/migrate02", DatabaseSQLite3, 2)
+	assertTableExist(t, db, "auth_user")
+	assertTableExist(t, db, "product")
+}
+
+func assertTableNotExist(t *testing.T, db *sqlx.DB, table string) {
+	t.Helper()
+
+	var result int
+	query := `SELECT COUNT(*) FROM ` + table
+	err := db.Get(&result, query)
+	assert.Equal(t, "no such table: "+table, err.Error())
+}
+
+//go:embed testdata/migrate03/*
+var migrate03Dir embed.FS
+
+func TestMigrateUp__Dirty_Then_Force__Integration(t *testing.T) {
+	db := newTestDB(t)
+
+	assert.PanicsWithError(t, "migration file '0002_add_product.sql': no such table: not_found_table", func() {
+		MigrateUp(db, migrate03Dir, "testdata/migrate03", DatabaseSQLite3)
+	})
+
+	row, err := getMigrationRow(context.Background(), db)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, null.New(SchemaMigration{
+		ID:       1,
+		Version:  2,
+		Filename: "0002_add_product.sql",
+		IsDirty:  true,
+	}), row)
+
+	// run again
+	assert.PanicsWithError(
+		t,
+		"database is in dirty state at version '0002' with file '0002_add_product.sql', "+
+			"repair it manually then use Force to set the version",
+		func() {
+			MigrateUp(db, migrate03Dir, "testdata/migrate03", DatabaseSQLite3)
+		},
+	)
+
+	// force version
+	Force(db, migrate03Dir, "testdata/migrate03", DatabaseSQLite3, 1)
+
+	row, err = getMigrationRow(context.Background(), db)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, null.New(SchemaMigration{
+		ID:       1,
+		Version:  1,
+		Filename: "0001_init.sql",
+		IsDirty:  false,
+	}), row)
+
+	historyList, err := getMigrationHistory(context.Background(), db)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(historyList))
+	assert.Equal(t, int64(1), historyList[0].Version)
+}
+
+//go:embed testdata/migrate04/*
+var migrate04Dir embed.FS
+
+func TestMigrateUp__Transactional_Failed__Integration(t *testing.T) {
+	db := newTestDB(t)
+
+	assert.PanicsWithError(t, "migration file '0002_add_product.sql': no such table: not_found_table", func() {
+		MigrateUp(db, migrate04Dir, "testdata/migrate04", DatabaseSQLite3)
+	})
+
+	// no partial state
+	assertTableExist(t, db, "auth_user")
+	assertTableNotExist(t, db, "product")
+
+	row, err := getMigrationRow(context.Background(), db)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, null.New(SchemaMigration{
+		ID:       1,
+		Version:  1,
+		Filename: "0001_init.sql",
+		IsDirty:  false,
+	}), row)
+
+	historyList, err := getMigrationHistory(context.Background(), db)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(historyList))
+}
+
+func TestMigrateUpContext__Integration(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	result, err := MigrateUpContext(ctx, db, migrate04Dir, "testdata/migrate04", DatabaseSQLite3)
+	assert.Equal(t, "migration file '0002_add_product.sql': no such table: not_found_table", err.Error())
+	assert.Equal(t, 1, len(result.Applied))
+	assert.Equal(t, "0001_init.sql", result.Applied[0].Filename)
+}
+
+func TestMigrateDownContext__Integration(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	result, err := MigrateUpContext(ctx, db, migrate02Dir, "testdata/migrate02", DatabaseSQLite3)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(result.Applied))
+	assert.Equal(t, "0002_add_product.up.sql", result.Applied[1].Filename)
+
+	result, err = MigrateDownContext(ctx, db, migrate02Dir, "testdata/migrate02", DatabaseSQLite3, 2)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(result.Applied))
+	assert.Equal(t, "0001_init.down.sql", result.Applied[1].Filename)
+	assert.Equal(t, true, result.Applied[1].IsDown)
+	assertTableNotExist(t, db, "auth_user")
+
+	// with canceled context
+	cancelCtx, cancel := context.WithCancel(ctx)
+	cancel()
+
+	result, err = MigrateUpContext(cancelCtx, db, migrate02Dir, "testdata/migrate02", DatabaseSQLite3)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, MigrateResult{}, result)
+}
+
+func TestPlan__DirFS__Integration(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	fsys := os.DirFS("testdata")
+
+	plan, err := Plan(ctx, db, fsys, "migrate02", DatabaseSQLite3)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(0), plan.CurrentVersion)
+	assert.Equal(t, 2, len(plan.Pending))
+	assert.Equal(t, "0001_init.up.sql", plan.Pending[0].Filename)
+	assert.Equal(t, "0002_add_product.up.sql", plan.Pending[1].Filename)
+
+	content, err := os.ReadFile("testdata/migrate02/0001_init.up.sql")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, content, plan.Pending[0].Content)
+
+	// not run by plan, the migration tables and the lock table are not created
+	assertTableNotExist(t, db, "auth_user")
+	assertTableNotExist(t, db, "schema_migration")
+	assertTableNotExist(t, db, "schema_migration_history")
+	assertTableNotExist(t, db, "schema_migration_lock")
+
+	result, err := MigrateToContext(ctx, db, fsys, "migrate02", DatabaseSQLite3, 1)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(result.Applied))
+	assertTableExist(t, db, "auth_user")
+
+	plan, err = Plan(ctx, db, fsys, "migrate02", DatabaseSQLite3)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(1), plan.CurrentVersion)
+	assert.Equal(t, 1, len(plan.Pending))
+	assert.Equal(t, "0002_add_product.up.sql", plan.Pending[0].Filename)
+}
+
+func TestStatus__Integration(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	status, err := Status(ctx, db, migrate02Dir, "testdata/migrate02", DatabaseSQLite3)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, MigrationStatus{
+		Pending: []string{"0001_init.up.sql", "0002_add_product.up.sql"},
+	}, status)
+
+	// read only, the migration tables are not created
+	assertTableNotExist(t, db, "schema_migration")
+	assertTableNotExist(t, db, "schema_migration_history")
+
+	MigrateTo(db, migrate02Dir, "testdata/migrate02", DatabaseSQLite3, 1)
+
+	status, err = Status(ctx, db, migrate02Dir, "testdata/migrate02", DatabaseSQLite3)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, MigrationStatus{
+		CurrentVersion:  1,
+		CurrentFilename: "0001_init.up.sql",
+		Pending:         []string{"0002_add_product.up.sql"},
+	}, status)
+
+	MigrateUp(db, migrate02Dir, "testdata/migrate02", DatabaseSQLite3)
+
+	status, err = Status(ctx, db, migrate02Dir, "testdata/migrate02", DatabaseSQLite3)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, true, status.UpToDate())
+}
+
+func TestMigrateUp__Concurrent__Integration(t *testing.T) {
+	db := newTestDB(t)
+
+	var wg sync.WaitGroup
+	results := make([]MigrateResult, 3)
+	errs := make([]error, 3)
+
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = MigrateUpContext(
+				context.Background(), db, migrate01Dir, "testdata/migrate01", DatabaseSQLite3,
+				WithLockTimeout(10*time.Second),
+			)
+		}()
+	}
+	wg.Wait()
+
+	numApplied := 0
+	for i := range results {
+		assert.Equal(t, nil, errs[i])
+		numApplied += len(results[i].Applied)
+	}
+	// each script is run exactly once
+	assert.Equal(t, 2, numApplied)
+
+	assertTableExist(t, db, "auth_user")
+	assertTableExist(t, db, "product")
+}
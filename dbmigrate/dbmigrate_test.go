@@ -1,10 +1,15 @@
 package dbmigrate
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io/fs"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -39,6 +44,24 @@ func TestParseMigrateFileName(t *testing.T) {
 		assert.Equal(t, migrateFile{}, output)
 	})
 
+	t.Run("up file", func(t *testing.T) {
+		output, err := parseMigrateFilename("0012_init.up.sql")
+		assert.Equal(t, nil, err)
+		assert.Equal(t, migrateFile{
+			version:  12,
+			filename: "0012_init.up.sql",
+		}, output)
+	})
+
+	t.Run("down file", func(t *testing.T) {
+		output, err := parseMigrateFilename("0012_init.down.sql")
+		assert.Equal(t, nil, err)
+		assert.Equal(t, migrateFile{
+			version:      12,
+			downFilename: "0012_init.down.sql",
+		}, output)
+	})
+
 	t.Run("version is zero", func(t *testing.T) {
 		output, err := parseMigrateFilename("0000_init.sql")
 		assert.Equal(t, errors.New("version number must start from 1"), err)
@@ -53,7 +76,7 @@ func TestValidateMigrateFiles(t *testing.T) {
 				version:  1,
 				filename: "0001_init.sql",
 			},
-		})
+		}, false)
 		assert.Equal(t, nil, err)
 	})
 
@@ -63,7 +86,7 @@ func TestValidateMigrateFiles(t *testing.T) {
 				version:  2,
 				filename: "0002_init.sql",
 			},
-		})
+		}, false)
 		assert.Equal(t, errors.New("missing version number '0001'"), err)
 	})
 
@@ -81,7 +104,7 @@ func TestValidateMigrateFiles(t *testing.T) {
 				version:  2,
 				filename: "0002_add_user.sql",
 			},
-		})
+		}, false)
 		assert.Equal(t, errors.New("missing version number '0003'"), err)
 	})
 
@@ -99,20 +122,94 @@ func TestValidateMigrateFiles(t *testing.T) {
 				version:  2,
 				filename: "0002_add_other.sql",
 			},
-		})
+		}, false)
 		assert.Equal(t, errors.New("duplicated version number '0002'"), err)
 	})
 
+	t.Run("version gaps", func(t *testing.T) {
+		files := []migrateFile{
+			{
+				version:  20261018120000,
+				filename: "20261018120000_add_user.sql",
+			},
+			{
+				version:  3,
+				filename: "0003_init.sql",
+			},
+		}
+		err := validateMigrateFiles(files, true)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []migrateFile{
+			{version: 3, filename: "0003_init.sql"},
+			{version: 20261018120000, filename: "20261018120000_add_user.sql"},
+		}, files)
+	})
+
+	t.Run("version gaps, duplicated", func(t *testing.T) {
+		err := validateMigrateFiles([]migrateFile{
+			{version: 3, filename: "0003_init.sql"},
+			{version: 3, filename: "0003_add_user.sql"},
+		}, true)
+		assert.Equal(t, errors.New("duplicated version number '0003'"), err)
+	})
+
 	t.Run("empty", func(t *testing.T) {
-		err := validateMigrateFiles(nil)
+		err := validateMigrateFiles(nil, false)
 		assert.Equal(t, errors.New("migration file list must not be empty"), err)
 	})
 }
 
+func TestGroupMigrateFiles(t *testing.T) {
+	t.Run("normal", func(t *testing.T) {
+		files, err := groupMigrateFiles([]migrateFile{
+			{version: 1, filename: "0001_init.up.sql"},
+			{version: 2, downFilename: "0002_add_user.down.sql"},
+			{version: 1, downFilename: "0001_init.down.sql"},
+			{version: 2, filename: "0002_add_user.up.sql"},
+			{version: 3, filename: "0003_add_index.sql"},
+		})
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []migrateFile{
+			{version: 1, filename: "0001_init.up.sql", downFilename: "0001_init.down.sql"},
+			{version: 2, filename: "0002_add_user.up.sql", downFilename: "0002_add_user.down.sql"},
+			{version: 3, filename: "0003_add_index.sql"},
+		}, files)
+	})
+
+	t.Run("duplicated up file", func(t *testing.T) {
+		files, err := groupMigrateFiles([]migrateFile{
+			{version: 1, filename: "0001_init.up.sql"},
+			{version: 1, filename: "0001_init.sql"},
+		})
+		assert.Equal(t, errors.New("duplicated version number '0001'"), err)
+		assert.Equal(t, []migrateFile(nil), files)
+	})
+
+	t.Run("duplicated down file", func(t *testing.T) {
+		_, err := groupMigrateFiles([]migrateFile{
+			{version: 1, downFilename: "0001_init.down.sql"},
+			{version: 1, filename: "0001_init.up.sql"},
+			{version: 1, downFilename: "0001_other.down.sql"},
+		})
+		assert.Equal(t, errors.New("duplicated down migration of version '0001'"), err)
+	})
+
+	t.Run("missing up file", func(t *testing.T) {
+		_, err := groupMigrateFiles([]migrateFile{
+			{version: 1, filename: "0001_init.up.sql"},
+			{version: 2, downFilename: "0002_add_user.down.sql"},
+		})
+		assert.Equal(t, errors.New("missing up migration file of version '0002'"), err)
+	})
+}
+
 type migrateTest struct {
 	createErr   error
 	createCalls int
 
+	missingTables  map[string]bool
+	tableExistsErr error
+
 	getRowValue null.Null[SchemaMigration]
 	getRowErr   error
 	getRowCalls int
@@ -123,11 +220,33 @@ type migrateTest struct {
 	runScriptErr    error
 	runScriptInputs []string
 
+	historyList   []MigrationHistory
+	getHistoryErr error
+	historyInputs []MigrationHistory
+	deleteInputs  []int64
+	forceHistory  []null.Null[MigrationHistory]
+
+	markErr error
+
+	transactional bool
+	txErr         error
+
+	ctx         context.Context
+	currentTime time.Time
+	result      MigrateResult
+
+	fileContents map[string]string
+
+	versionGaps bool
+	outOfOrder  bool
+
 	actions []string
 }
 
 func newMigrateTest() *migrateTest {
 	m := &migrateTest{}
+	m.ctx = context.Background()
+	m.currentTime = time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
 
 	return m
 }
@@ -145,31 +264,107 @@ func (e dirEntryTest) Name() string {
 	return e.name
 }
 
-func (m *migrateTest) executeMigrate(entries ...dirEntryTest) error {
-	inputEntries := mapSlice(entries, func(e dirEntryTest) fs.DirEntry {
-		return e
-	})
-	return doMigrateUp(
-		inputEntries,
-		func() error {
+func (m *migrateTest) newActions() migrateActions {
+	return migrateActions{
+		createTable: func() error {
 			m.createCalls++
 			return m.createErr
 		},
-		func() (null.Null[SchemaMigration], error) {
+		tableExists: func(table string) (bool, error) {
+			return !m.missingTables[table], m.tableExistsErr
+		},
+		getRow: func() (null.Null[SchemaMigration], error) {
 			m.getRowCalls++
 			return m.getRowValue, m.getRowErr
 		},
-		func(row SchemaMigration) error {
+		upsertRow: func(row SchemaMigration) error {
 			m.addAction("upsert_row")
 			m.upsertInputs = append(m.upsertInputs, row)
 			return m.upsertErr
 		},
-		func(filename string) error {
+		getHistory: func() ([]MigrationHistory, error) {
+			return m.historyList, m.getHistoryErr
+		},
+		markApplied: func(row SchemaMigration, history MigrationHistory) error {
+			m.addAction("mark_applied")
+			m.upsertInputs = append(m.upsertInputs, row)
+			m.historyInputs = append(m.historyInputs, history)
+			return m.markErr
+		},
+		markReverted: func(row SchemaMigration, revertedVersion int64) error {
+			m.addAction("mark_reverted")
+			m.upsertInputs = append(m.upsertInputs, row)
+			m.deleteInputs = append(m.deleteInputs, revertedVersion)
+			return m.markErr
+		},
+		force: func(row SchemaMigration, history null.Null[MigrationHistory]) error {
+			m.addAction("force")
+			m.upsertInputs = append(m.upsertInputs, row)
+			m.forceHistory = append(m.forceHistory, history)
+			return m.markErr
+		},
+		readFile: func(filename string) ([]byte, error) {
+			content, ok := m.fileContents[filename]
+			if !ok {
+				content = "content of " + filename
+			}
+			return []byte(content), nil
+		},
+		runScript: func(filename string) error {
 			m.addAction("run_script")
 			m.runScriptInputs = append(m.runScriptInputs, filename)
 			return m.runScriptErr
 		},
-	)
+
+		transactional: m.transactional,
+		applyInTx: func(filename string, row SchemaMigration, history MigrationHistory) error {
+			m.addAction("apply_in_tx")
+			m.runScriptInputs = append(m.runScriptInputs, filename)
+			m.upsertInputs = append(m.upsertInputs, row)
+			m.historyInputs = append(m.historyInputs, history)
+			return m.txErr
+		},
+		revertInTx: func(filename string, row SchemaMigration, revertedVersion int64) error {
+			m.addAction("revert_in_tx")
+			m.runScriptInputs = append(m.runScriptInputs, filename)
+			m.upsertInputs = append(m.upsertInputs, row)
+			m.deleteInputs = append(m.deleteInputs, revertedVersion)
+			return m.txErr
+		},
+
+		versionGaps: m.versionGaps,
+		outOfOrder:  m.outOfOrder,
+
+		now: func() time.Time {
+			// each call increases the current time by 1 second
+			m.currentTime = m.currentTime.Add(time.Second)
+			return m.currentTime
+		},
+	}
+}
+
+func toDirEntries(entries []dirEntryTest) []fs.DirEntry {
+	return mapSlice(entries, func(e dirEntryTest) fs.DirEntry {
+		return e
+	})
+}
+
+func (m *migrateTest) executeMigrate(entries ...dirEntryTest) error {
+	var err error
+	m.result, err = doMigrateUp(m.ctx, toDirEntries(entries), m.newActions())
+	return err
+}
+
+func (m *migrateTest) executeMigrateDown(steps int, entries ...dirEntryTest) error {
+	var err error
+	m.result, err = doMigrateDown(m.ctx, toDirEntries(entries), m.newActions(), steps)
+	return err
+}
+
+func (m *migrateTest) executeMigrateTo(version int64, entries ...dirEntryTest) error {
+	var err error
+	m.result, err = doMigrateTo(m.ctx, toDirEntries(entries), m.newActions(), version)
+	return err
 }
 
 func TestDoMigrateUp(t *testing.T) {
@@ -208,7 +403,7 @@ func TestDoMigrateUp(t *testing.T) {
 		assert.Equal(t, []string{
 			"upsert_row",
 			"run_script",
-			"upsert_row",
+			"mark_applied",
 		}, m.actions)
 	})
 
@@ -261,6 +456,12 @@ func TestDoMigrateUp(t *testing.T) {
 				Filename: "0001_init.sql",
 				IsDirty:  true,
 			},
+			{
+				ID:       1,
+				Version:  1,
+				Filename: "0001_init.sql",
+				IsDirty:  false,
+			},
 			{
 				ID:       1,
 				Version:  2,
@@ -285,9 +486,10 @@ func TestDoMigrateUp(t *testing.T) {
 		assert.Equal(t, []string{
 			"upsert_row",
 			"run_script",
+			"mark_applied",
 			"upsert_row",
 			"run_script",
-			"upsert_row",
+			"mark_applied",
 		}, m.actions)
 	})
 
@@ -318,6 +520,12 @@ func TestDoMigrateUp(t *testing.T) {
 				Filename: "0003_add_index.sql",
 				IsDirty:  true,
 			},
+			{
+				ID:       1,
+				Version:  3,
+				Filename: "0003_add_index.sql",
+				IsDirty:  false,
+			},
 			{
 				ID:       1,
 				Version:  4,
@@ -456,10 +664,929 @@ func TestDoMigrateUp(t *testing.T) {
 	})
 }
 
-func mapSlice[A, B any](list []A, fn func(x A) B) []B {
-	result := make([]B, 0, len(list))
-	for _, x := range list {
-		result = append(result, fn(x))
+func testChecksum(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestDoMigrateUp__Checksum(t *testing.T) {
+	t.Run("store checksum", func(t *testing.T) {
+		m := newMigrateTest()
+
+		err := m.executeMigrate(
+			dirEntryTest{name: "0001_init.sql"},
+			dirEntryTest{name: "0002_add_user.sql"},
+		)
+		assert.Equal(t, nil, err)
+
+		assert.Equal(t, []MigrationHistory{
+			{
+				Version:  1,
+				Filename: "0001_init.sql",
+				Checksum: testChecksum("content of 0001_init.sql"),
+			},
+			{
+				Version:  2,
+				Filename: "0002_add_user.sql",
+				Checksum: testChecksum("content of 0002_add_user.sql"),
+			},
+		}, m.historyInputs)
+	})
+
+	t.Run("applied files not changed", func(t *testing.T) {
+		m := newMigrateTest()
+		m.getRowValue = null.New(SchemaMigration{
+			ID:       1,
+			Version:  1,
+			Filename: "0001_init.sql",
+		})
+		m.historyList = []MigrationHistory{
+			{
+				Version:  1,
+				Filename: "0001_init.sql",
+				Checksum: testChecksum("content of 0001_init.sql"),
+			},
+		}
+
+		err := m.executeMigrate(
+			dirEntryTest{name: "0001_init.sql"},
+			dirEntryTest{name: "0002_add_user.sql"},
+		)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []string{"0002_add_user.sql"}, m.runScriptInputs)
+	})
+
+	t.Run("applied file changed", func(t *testing.T) {
+		m := newMigrateTest()
+		m.getRowValue = null.New(SchemaMigration{
+			ID:       1,
+			Version:  1,
+			Filename: "0001_init.sql",
+		})
+		m.historyList = []MigrationHistory{
+			{
+				Version:  1,
+				Filename: "0001_init.sql",
+				Checksum: testChecksum("content of 0001_init.sql"),
+			},
+		}
+		m.fileContents = map[string]string{
+			"0001_init.sql": "changed content",
+		}
+
+		err := m.executeMigrate(
+			dirEntryTest{name: "0001_init.sql"},
+			dirEntryTest{name: "0002_add_user.sql"},
+		)
+		assert.Equal(t, fmt.Errorf(
+			"content of the applied migration file '0001_init.sql' has changed, expected checksum '%s', got '%s'",
+			testChecksum("content of 0001_init.sql"), testChecksum("changed content"),
+		), err)
+		assert.Equal(t, []string(nil), m.actions)
+	})
+
+	t.Run("get history error", func(t *testing.T) {
+		m := newMigrateTest()
+		m.getHistoryErr = errors.New("get history error")
+
+		err := m.executeMigrate(dirEntryTest{name: "0001_init.sql"})
+		assert.Equal(t, m.getHistoryErr, err)
+		assert.Equal(t, []string(nil), m.actions)
+	})
+
+	t.Run("mark applied error", func(t *testing.T) {
+		m := newMigrateTest()
+		m.markErr = errors.New("mark applied error")
+
+		err := m.executeMigrate(
+			dirEntryTest{name: "0001_init.sql"},
+			dirEntryTest{name: "0002_add_user.sql"},
+		)
+		assert.Equal(t, m.markErr, err)
+		assert.Equal(t, []string{
+			"upsert_row",
+			"run_script",
+			"mark_applied",
+		}, m.actions)
+	})
+
+	t.Run("migrate down deletes history", func(t *testing.T) {
+		m := newMigrateTest()
+		m.getRowValue = null.New(SchemaMigration{
+			ID:       1,
+			Version:  2,
+			Filename: "0002_add_user.up.sql",
+		})
+
+		err := m.executeMigrateDown(
+			2,
+			dirEntryTest{name: "0001_init.up.sql"},
+			dirEntryTest{name: "0001_init.down.sql"},
+			dirEntryTest{name: "0002_add_user.up.sql"},
+			dirEntryTest{name: "0002_add_user.down.sql"},
+		)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []int64{2, 1}, m.deleteInputs)
+	})
+}
+
+func TestDoMigrate__Dirty_State(t *testing.T) {
+	entries := []dirEntryTest{
+		{name: "0001_init.up.sql"},
+		{name: "0001_init.down.sql"},
+		{name: "0002_add_user.up.sql"},
+		{name: "0002_add_user.down.sql"},
 	}
-	return result
+
+	dirtyErr := errors.New(
+		"database is in dirty state at version '0002' with file '0002_add_user.up.sql', " +
+			"repair it manually then use Force to set the version",
+	)
+
+	newDirtyTest := func() *migrateTest {
+		m := newMigrateTest()
+		m.getRowValue = null.New(SchemaMigration{
+			ID:       1,
+			Version:  2,
+			Filename: "0002_add_user.up.sql",
+			IsDirty:  true,
+		})
+		return m
+	}
+
+	t.Run("migrate up", func(t *testing.T) {
+		m := newDirtyTest()
+		err := m.executeMigrate(entries...)
+		assert.Equal(t, dirtyErr, err)
+		assert.Equal(t, []string(nil), m.actions)
+	})
+
+	t.Run("migrate down", func(t *testing.T) {
+		m := newDirtyTest()
+		err := m.executeMigrateDown(1, entries...)
+		assert.Equal(t, dirtyErr, err)
+		assert.Equal(t, []string(nil), m.actions)
+	})
+
+	t.Run("migrate to", func(t *testing.T) {
+		m := newDirtyTest()
+		err := m.executeMigrateTo(0, entries...)
+		assert.Equal(t, dirtyErr, err)
+		assert.Equal(t, []string(nil), m.actions)
+	})
+}
+
+func TestDoForce(t *testing.T) {
+	entries := []dirEntryTest{
+		{name: "0001_init.sql"},
+		{name: "0002_add_user.sql"},
+	}
+
+	t.Run("normal", func(t *testing.T) {
+		m := newMigrateTest()
+
+		err := doForce(toDirEntries(entries), m.newActions(), 1)
+		assert.Equal(t, nil, err)
+
+		assert.Equal(t, 1, m.createCalls)
+		assert.Equal(t, []string{"force"}, m.actions)
+		assert.Equal(t, []SchemaMigration{
+			{
+				ID:       1,
+				Version:  1,
+				Filename: "0001_init.sql",
+				IsDirty:  false,
+			},
+		}, m.upsertInputs)
+		assert.Equal(t, []null.Null[MigrationHistory]{
+			null.New(MigrationHistory{
+				Version:  1,
+				Filename: "0001_init.sql",
+				Checksum: testChecksum("content of 0001_init.sql"),
+			}),
+		}, m.forceHistory)
+	})
+
+	t.Run("version zero", func(t *testing.T) {
+		m := newMigrateTest()
+
+		err := doForce(toDirEntries(entries), m.newActions(), 0)
+		assert.Equal(t, nil, err)
+
+		assert.Equal(t, []SchemaMigration{
+			{ID: 1},
+		}, m.upsertInputs)
+		assert.Equal(t, []null.Null[MigrationHistory]{{}}, m.forceHistory)
+	})
+
+	t.Run("version not found", func(t *testing.T) {
+		m := newMigrateTest()
+
+		err := doForce(toDirEntries(entries), m.newActions(), 3)
+		assert.Equal(t, errors.New("not found version '0003' in migration file list"), err)
+		assert.Equal(t, 0, m.createCalls)
+		assert.Equal(t, []string(nil), m.actions)
+	})
+}
+
+func TestDoPlan(t *testing.T) {
+	entries := []dirEntryTest{
+		{name: "0001_init.sql"},
+		{name: "0002_add_user.sql"},
+		{name: "0003_add_index.sql"},
+	}
+
+	t.Run("from version one", func(t *testing.T) {
+		m := newMigrateTest()
+		m.getRowValue = null.New(SchemaMigration{
+			ID:       1,
+			Version:  1,
+			Filename: "0001_init.sql",
+		})
+
+		plan, err := doPlan(toDirEntries(entries), m.newActions())
+		assert.Equal(t, nil, err)
+		assert.Equal(t, MigratePlan{
+			CurrentVersion: 1,
+			Pending: []PendingMigration{
+				{
+					Version:  2,
+					Filename: "0002_add_user.sql",
+					Content:  []byte("content of 0002_add_user.sql"),
+				},
+				{
+					Version:  3,
+					Filename: "0003_add_index.sql",
+					Content:  []byte("content of 0003_add_index.sql"),
+				},
+			},
+		}, plan)
+
+		// no script is run
+		assert.Equal(t, []string(nil), m.actions)
+	})
+
+	t.Run("up to date", func(t *testing.T) {
+		m := newMigrateTest()
+		m.getRowValue = null.New(SchemaMigration{
+			ID:       1,
+			Version:  3,
+			Filename: "0003_add_index.sql",
+		})
+
+		plan, err := doPlan(toDirEntries(entries), m.newActions())
+		assert.Equal(t, nil, err)
+		assert.Equal(t, MigratePlan{CurrentVersion: 3}, plan)
+	})
+
+	t.Run("tables not existed", func(t *testing.T) {
+		m := newMigrateTest()
+		m.missingTables = map[string]bool{
+			"schema_migration":         true,
+			"schema_migration_history": true,
+		}
+		m.getRowErr = errors.New("no such table: schema_migration")
+
+		plan, err := doPlan(toDirEntries(entries), m.newActions())
+		assert.Equal(t, nil, err)
+		assert.Equal(t, 3, len(plan.Pending))
+		assert.Equal(t, int64(0), plan.CurrentVersion)
+		assert.Equal(t, "0001_init.sql", plan.Pending[0].Filename)
+
+		assert.Equal(t, 0, m.getRowCalls)
+		assert.Equal(t, 0, m.createCalls)
+	})
+
+	t.Run("dirty", func(t *testing.T) {
+		m := newMigrateTest()
+		m.getRowValue = null.New(SchemaMigration{
+			ID:       1,
+			Version:  2,
+			Filename: "0002_add_user.sql",
+			IsDirty:  true,
+		})
+
+		plan, err := doPlan(toDirEntries(entries), m.newActions())
+		assert.Equal(t, errors.New(
+			"database is in dirty state at version '0002' with file '0002_add_user.sql', "+
+				"repair it manually then use Force to set the version",
+		), err)
+		assert.Equal(t, MigratePlan{}, plan)
+	})
+}
+
+func TestDoStatus(t *testing.T) {
+	entries := []dirEntryTest{
+		{name: "0001_init.sql"},
+		{name: "0002_add_user.sql"},
+		{name: "0003_add_index.sql"},
+	}
+
+	t.Run("empty database", func(t *testing.T) {
+		m := newMigrateTest()
+
+		status, err := doStatus(toDirEntries(entries), m.newActions())
+		assert.Equal(t, nil, err)
+		assert.Equal(t, MigrationStatus{
+			Pending: []string{"0001_init.sql", "0002_add_user.sql", "0003_add_index.sql"},
+		}, status)
+		assert.Equal(t, false, status.UpToDate())
+		assert.Equal(t, 0, m.createCalls)
+	})
+
+	t.Run("tables not existed", func(t *testing.T) {
+		m := newMigrateTest()
+		m.missingTables = map[string]bool{
+			"schema_migration":         true,
+			"schema_migration_history": true,
+		}
+		m.getRowErr = errors.New("no such table: schema_migration")
+		m.getHistoryErr = errors.New("no such table: schema_migration_history")
+
+		status, err := doStatus(toDirEntries(entries), m.newActions())
+		assert.Equal(t, nil, err)
+		assert.Equal(t, MigrationStatus{
+			Pending: []string{"0001_init.sql", "0002_add_user.sql", "0003_add_index.sql"},
+		}, status)
+		assert.Equal(t, 0, m.getRowCalls)
+		assert.Equal(t, 0, m.createCalls)
+	})
+
+	t.Run("table exists error", func(t *testing.T) {
+		m := newMigrateTest()
+		m.tableExistsErr = errors.New("table exists error")
+
+		status, err := doStatus(toDirEntries(entries), m.newActions())
+		assert.Equal(t, errors.New("table exists error"), err)
+		assert.Equal(t, MigrationStatus{}, status)
+	})
+
+	t.Run("up to date", func(t *testing.T) {
+		m := newMigrateTest()
+		m.getRowValue = null.New(SchemaMigration{
+			ID:       1,
+			Version:  3,
+			Filename: "0003_add_index.sql",
+		})
+
+		status, err := doStatus(toDirEntries(entries), m.newActions())
+		assert.Equal(t, nil, err)
+		assert.Equal(t, MigrationStatus{
+			CurrentVersion:  3,
+			CurrentFilename: "0003_add_index.sql",
+		}, status)
+		assert.Equal(t, true, status.UpToDate())
+	})
+
+	t.Run("dirty", func(t *testing.T) {
+		m := newMigrateTest()
+		m.getRowValue = null.New(SchemaMigration{
+			ID:       1,
+			Version:  2,
+			Filename: "0002_add_user.sql",
+			IsDirty:  true,
+		})
+
+		status, err := doStatus(toDirEntries(entries), m.newActions())
+		assert.Equal(t, nil, err)
+		assert.Equal(t, MigrationStatus{
+			CurrentVersion:  2,
+			CurrentFilename: "0002_add_user.sql",
+			IsDirty:         true,
+			Pending:         []string{"0003_add_index.sql"},
+		}, status)
+		assert.Equal(t, false, status.UpToDate())
+	})
+
+	t.Run("unknown applied versions", func(t *testing.T) {
+		m := newMigrateTest()
+		m.getRowValue = null.New(SchemaMigration{
+			ID:       1,
+			Version:  5,
+			Filename: "0005_add_product.sql",
+		})
+		m.historyList = []MigrationHistory{
+			{Version: 1, Filename: "0001_init.sql"},
+			{Version: 2, Filename: "0002_add_user.sql"},
+			{Version: 3, Filename: "0003_add_index.sql"},
+			{Version: 4, Filename: "0004_add_order.sql"},
+			{Version: 5, Filename: "0005_add_product.sql"},
+		}
+
+		status, err := doStatus(toDirEntries(entries), m.newActions())
+		assert.Equal(t, nil, err)
+		assert.Equal(t, MigrationStatus{
+			CurrentVersion:  5,
+			CurrentFilename: "0005_add_product.sql",
+			UnknownApplied:  []int64{4, 5},
+		}, status)
+		assert.Equal(t, false, status.UpToDate())
+	})
+
+	t.Run("get row error", func(t *testing.T) {
+		m := newMigrateTest()
+		m.getRowErr = errors.New("get row error")
+
+		status, err := doStatus(toDirEntries(entries), m.newActions())
+		assert.Equal(t, errors.New("get row error"), err)
+		assert.Equal(t, MigrationStatus{}, status)
+	})
+}
+
+func TestDoMigrate__Transactional(t *testing.T) {
+	entries := []dirEntryTest{
+		{name: "0001_init.up.sql"},
+		{name: "0001_init.down.sql"},
+		{name: "0002_add_index.up.sql"},
+		{name: "0002_add_index.down.sql"},
+	}
+
+	t.Run("migrate up", func(t *testing.T) {
+		m := newMigrateTest()
+		m.transactional = true
+		m.fileContents = map[string]string{
+			"0002_add_index.up.sql": "-- some comment\n\n-- dbmigrate:no-transaction\nCREATE INDEX CONCURRENTLY",
+		}
+
+		err := m.executeMigrate(entries...)
+		assert.Equal(t, nil, err)
+
+		assert.Equal(t, []string{
+			"apply_in_tx",
+			"upsert_row",
+			"run_script",
+			"mark_applied",
+		}, m.actions)
+
+		assert.Equal(t, []SchemaMigration{
+			{
+				ID:       1,
+				Version:  1,
+				Filename: "0001_init.up.sql",
+				IsDirty:  false,
+			},
+			{
+				ID:       1,
+				Version:  2,
+				Filename: "0002_add_index.up.sql",
+				IsDirty:  true,
+			},
+			{
+				ID:       1,
+				Version:  2,
+				Filename: "0002_add_index.up.sql",
+				IsDirty:  false,
+			},
+		}, m.upsertInputs)
+
+		assert.Equal(t, []MigrationHistory{
+			{
+				Version:  1,
+				Filename: "0001_init.up.sql",
+				Checksum: testChecksum("content of 0001_init.up.sql"),
+			},
+			{
+				Version:  2,
+				Filename: "0002_add_index.up.sql",
+				Checksum: testChecksum(m.fileContents["0002_add_index.up.sql"]),
+			},
+		}, m.historyInputs)
+	})
+
+	t.Run("migrate up, tx error", func(t *testing.T) {
+		m := newMigrateTest()
+		m.transactional = true
+		m.txErr = errors.New("tx error")
+
+		err := m.executeMigrate(entries...)
+		assert.Equal(t, m.txErr, err)
+		assert.Equal(t, []string{"apply_in_tx"}, m.actions)
+	})
+
+	t.Run("migrate down", func(t *testing.T) {
+		m := newMigrateTest()
+		m.transactional = true
+		m.getRowValue = null.New(SchemaMigration{
+			ID:       1,
+			Version:  2,
+			Filename: "0002_add_index.up.sql",
+		})
+
+		err := m.executeMigrateDown(2, entries...)
+		assert.Equal(t, nil, err)
+
+		assert.Equal(t, []string{
+			"revert_in_tx",
+			"revert_in_tx",
+		}, m.actions)
+		assert.Equal(t, []string{
+			"0002_add_index.down.sql",
+			"0001_init.down.sql",
+		}, m.runScriptInputs)
+		assert.Equal(t, []SchemaMigration{
+			{
+				ID:       1,
+				Version:  1,
+				Filename: "0001_init.up.sql",
+			},
+			{
+				ID: 1,
+			},
+		}, m.upsertInputs)
+		assert.Equal(t, []int64{2, 1}, m.deleteInputs)
+	})
+}
+
+func TestHasNoTransactionHeader(t *testing.T) {
+	assert.Equal(t, true, hasNoTransactionHeader([]byte("-- dbmigrate:no-transaction\nCREATE INDEX")))
+	assert.Equal(t, true, hasNoTransactionHeader([]byte("\n-- title\n  -- dbmigrate:no-transaction  \r\nSELECT 1")))
+	assert.Equal(t, false, hasNoTransactionHeader([]byte("CREATE INDEX;\n-- dbmigrate:no-transaction\n")))
+	assert.Equal(t, false, hasNoTransactionHeader([]byte("-- dbmigrate:no-transactions\n")))
+	assert.Equal(t, false, hasNoTransactionHeader(nil))
+}
+
+func TestDoMigrate__Result(t *testing.T) {
+	entries := []dirEntryTest{
+		{name: "0001_init.up.sql"},
+		{name: "0001_init.down.sql"},
+		{name: "0002_add_user.up.sql"},
+		{name: "0002_add_user.down.sql"},
+	}
+
+	t.Run("migrate up", func(t *testing.T) {
+		m := newMigrateTest()
+
+		err := m.executeMigrate(entries...)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, MigrateResult{
+			Applied: []AppliedMigration{
+				{
+					Version:  1,
+					Filename: "0001_init.up.sql",
+					Duration: time.Second,
+				},
+				{
+					Version:  2,
+					Filename: "0002_add_user.up.sql",
+					Duration: time.Second,
+				},
+			},
+		}, m.result)
+	})
+
+	t.Run("migrate down", func(t *testing.T) {
+		m := newMigrateTest()
+		m.getRowValue = null.New(SchemaMigration{
+			ID:       1,
+			Version:  2,
+			Filename: "0002_add_user.up.sql",
+		})
+
+		err := m.executeMigrateDown(2, entries...)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, MigrateResult{
+			Applied: []AppliedMigration{
+				{
+					Version:  2,
+					Filename: "0002_add_user.down.sql",
+					IsDown:   true,
+					Duration: time.Second,
+				},
+				{
+					Version:  1,
+					Filename: "0001_init.down.sql",
+					IsDown:   true,
+					Duration: time.Second,
+				},
+			},
+		}, m.result)
+	})
+
+	t.Run("error in the middle", func(t *testing.T) {
+		m := newMigrateTest()
+		m.transactional = true
+		m.fileContents = map[string]string{
+			"0002_add_user.up.sql": "-- dbmigrate:no-transaction",
+		}
+		m.runScriptErr = errors.New("run script error")
+
+		err := m.executeMigrate(entries...)
+		assert.Equal(t, m.runScriptErr, err)
+		assert.Equal(t, MigrateResult{
+			Applied: []AppliedMigration{
+				{
+					Version:  1,
+					Filename: "0001_init.up.sql",
+					Duration: time.Second,
+				},
+			},
+		}, m.result)
+	})
+
+	t.Run("context canceled", func(t *testing.T) {
+		m := newMigrateTest()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		m.ctx = ctx
+
+		err := m.executeMigrate(entries...)
+		assert.Equal(t, context.Canceled, err)
+		assert.Equal(t, MigrateResult{}, m.result)
+		assert.Equal(t, []string(nil), m.actions)
+
+		err = m.executeMigrateTo(0, entries...)
+		assert.Equal(t, nil, err)
+
+		m.getRowValue = null.New(SchemaMigration{
+			ID:       1,
+			Version:  2,
+			Filename: "0002_add_user.up.sql",
+		})
+		err = m.executeMigrateDown(1, entries...)
+		assert.Equal(t, context.Canceled, err)
+		assert.Equal(t, []string(nil), m.actions)
+	})
+}
+
+func TestDoMigrateDown(t *testing.T) {
+	entries := []dirEntryTest{
+		{name: "0001_init.up.sql"},
+		{name: "0001_init.down.sql"},
+		{name: "0002_add_user.up.sql"},
+		{name: "0002_add_user.down.sql"},
+		{name: "0003_add_index.up.sql"},
+		{name: "0003_add_index.down.sql"},
+	}
+
+	t.Run("normal", func(t *testing.T) {
+		m := newMigrateTest()
+		m.getRowValue = null.New(SchemaMigration{
+			ID:       1,
+			Version:  3,
+			Filename: "0003_add_index.up.sql",
+		})
+
+		err := m.executeMigrateDown(2, entries...)
+		assert.Equal(t, nil, err)
+
+		assert.Equal(t, []SchemaMigration{
+			{
+				ID:       1,
+				Version:  3,
+				Filename: "0003_add_index.down.sql",
+				IsDirty:  true,
+			},
+			{
+				ID:       1,
+				Version:  2,
+				Filename: "0002_add_user.up.sql",
+				IsDirty:  false,
+			},
+			{
+				ID:       1,
+				Version:  2,
+				Filename: "0002_add_user.down.sql",
+				IsDirty:  true,
+			},
+			{
+				ID:       1,
+				Version:  1,
+				Filename: "0001_init.up.sql",
+				IsDirty:  false,
+			},
+		}, m.upsertInputs)
+
+		assert.Equal(t, []string{
+			"0003_add_index.down.sql",
+			"0002_add_user.down.sql",
+		}, m.runScriptInputs)
+
+		assert.Equal(t, []string{
+			"upsert_row",
+			"run_script",
+			"mark_reverted",
+			"upsert_row",
+			"run_script",
+			"mark_reverted",
+		}, m.actions)
+	})
+
+	t.Run("revert all", func(t *testing.T) {
+		m := newMigrateTest()
+		m.getRowValue = null.New(SchemaMigration{
+			ID:       1,
+			Version:  1,
+			Filename: "0001_init.up.sql",
+		})
+
+		err := m.executeMigrateDown(1, entries...)
+		assert.Equal(t, nil, err)
+
+		assert.Equal(t, []SchemaMigration{
+			{
+				ID:       1,
+				Version:  1,
+				Filename: "0001_init.down.sql",
+				IsDirty:  true,
+			},
+			{
+				ID:      1,
+				Version: 0,
+				IsDirty: false,
+			},
+		}, m.upsertInputs)
+
+		assert.Equal(t, []string{"0001_init.down.sql"}, m.runScriptInputs)
+	})
+
+	t.Run("steps bigger than current version", func(t *testing.T) {
+		m := newMigrateTest()
+		m.getRowValue = null.New(SchemaMigration{
+			ID:       1,
+			Version:  2,
+			Filename: "0002_add_user.up.sql",
+		})
+
+		err := m.executeMigrateDown(3, entries...)
+		assert.Equal(t, errors.New("can not migrate down 3 steps from version '0002'"), err)
+		assert.Equal(t, []string(nil), m.actions)
+	})
+
+	t.Run("no migration row", func(t *testing.T) {
+		m := newMigrateTest()
+
+		err := m.executeMigrateDown(1, entries...)
+		assert.Equal(t, errors.New("can not migrate down 1 steps from version '0000'"), err)
+	})
+
+	t.Run("steps not positive", func(t *testing.T) {
+		m := newMigrateTest()
+
+		err := m.executeMigrateDown(0, entries...)
+		assert.Equal(t, errors.New("number of steps to migrate down must be positive"), err)
+		assert.Equal(t, 0, m.createCalls)
+	})
+
+	t.Run("missing down file", func(t *testing.T) {
+		m := newMigrateTest()
+		m.getRowValue = null.New(SchemaMigration{
+			ID:       1,
+			Version:  2,
+			Filename: "0002_add_user.sql",
+		})
+
+		err := m.executeMigrateDown(
+			2,
+			dirEntryTest{name: "0001_init.up.sql"},
+			dirEntryTest{name: "0002_add_user.sql"},
+			dirEntryTest{name: "0001_init.down.sql"},
+		)
+		assert.Equal(t, errors.New("missing down migration file of version '0002'"), err)
+		assert.Equal(t, []string(nil), m.actions)
+	})
+
+	t.Run("run script error", func(t *testing.T) {
+		m := newMigrateTest()
+		m.getRowValue = null.New(SchemaMigration{
+			ID:       1,
+			Version:  3,
+			Filename: "0003_add_index.up.sql",
+		})
+		m.runScriptErr = errors.New("run script error")
+
+		err := m.executeMigrateDown(2, entries...)
+		assert.Equal(t, m.runScriptErr, err)
+
+		assert.Equal(t, []SchemaMigration{
+			{
+				ID:       1,
+				Version:  3,
+				Filename: "0003_add_index.down.sql",
+				IsDirty:  true,
+			},
+		}, m.upsertInputs)
+	})
+}
+
+func TestDoMigrateTo(t *testing.T) {
+	entries := []dirEntryTest{
+		{name: "0001_init.up.sql"},
+		{name: "0001_init.down.sql"},
+		{name: "0002_add_user.up.sql"},
+		{name: "0002_add_user.down.sql"},
+		{name: "0003_add_index.up.sql"},
+		{name: "0003_add_index.down.sql"},
+	}
+
+	t.Run("up", func(t *testing.T) {
+		m := newMigrateTest()
+		m.getRowValue = null.New(SchemaMigration{
+			ID:       1,
+			Version:  1,
+			Filename: "0001_init.up.sql",
+		})
+
+		err := m.executeMigrateTo(2, entries...)
+		assert.Equal(t, nil, err)
+
+		assert.Equal(t, []SchemaMigration{
+			{
+				ID:       1,
+				Version:  2,
+				Filename: "0002_add_user.up.sql",
+				IsDirty:  true,
+			},
+			{
+				ID:       1,
+				Version:  2,
+				Filename: "0002_add_user.up.sql",
+				IsDirty:  false,
+			},
+		}, m.upsertInputs)
+		assert.Equal(t, []string{"0002_add_user.up.sql"}, m.runScriptInputs)
+	})
+
+	t.Run("down", func(t *testing.T) {
+		m := newMigrateTest()
+		m.getRowValue = null.New(SchemaMigration{
+			ID:       1,
+			Version:  3,
+			Filename: "0003_add_index.up.sql",
+		})
+
+		err := m.executeMigrateTo(2, entries...)
+		assert.Equal(t, nil, err)
+
+		assert.Equal(t, []SchemaMigration{
+			{
+				ID:       1,
+				Version:  3,
+				Filename: "0003_add_index.down.sql",
+				IsDirty:  true,
+			},
+			{
+				ID:       1,
+				Version:  2,
+				Filename: "0002_add_user.up.sql",
+				IsDirty:  false,
+			},
+		}, m.upsertInputs)
+		assert.Equal(t, []string{"0003_add_index.down.sql"}, m.runScriptInputs)
+	})
+
+	t.Run("same version", func(t *testing.T) {
+		m := newMigrateTest()
+		m.getRowValue = null.New(SchemaMigration{
+			ID:       1,
+			Version:  2,
+			Filename: "0002_add_user.up.sql",
+		})
+
+		err := m.executeMigrateTo(2, entries...)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []string(nil), m.actions)
+	})
+
+	t.Run("version not found", func(t *testing.T) {
+		m := newMigrateTest()
+
+		err := m.executeMigrateTo(4, entries...)
+		assert.Equal(t, errors.New("not found version '0004' in migration file list"), err)
+		assert.Equal(t, []string(nil), m.actions)
+	})
+}
+
+func mapSlice[A, B any](list []A, fn func(x A) B) []B {
+	result := make([]B, 0, len(list))
+	for _, x := range list {
+		result = append(result, fn(x))
+	}
+	return result
+}
+
+func TestNextMigrationVersion(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		version, err := NextMigrationVersion(fstest.MapFS{"migrations": &fstest.MapFile{Mode: fs.ModeDir}}, "migrations")
+		assert.Equal(t, nil, err)
+		assert.Equal(t, int64(1), version)
+	})
+
+	t.Run("with up & down files", func(t *testing.T) {
+		version, err := NextMigrationVersion(fstest.MapFS{
+			"migrations/0001_init.up.sql":   &fstest.MapFile{},
+			"migrations/0001_init.down.sql": &fstest.MapFile{},
+			"migrations/0002_add_user.sql":  &fstest.MapFile{},
+		}, "migrations")
+		assert.Equal(t, nil, err)
+		assert.Equal(t, int64(3), version)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := NextMigrationVersion(fstest.MapFS{
+			"migrations/0001_init.sql":     &fstest.MapFile{},
+			"migrations/0003_add_user.sql": &fstest.MapFile{},
+		}, "migrations")
+		assert.Equal(t, errors.New("missing version number '0002'"), err)
+	})
 }
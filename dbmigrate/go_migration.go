@@ -0,0 +1,111 @@
+package dbmigrate
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// GoMigrationFunc is a migration written in Go, e.g. for backfilling data.
+// It is always run inside a transaction
+type GoMigrationFunc func(ctx context.Context, tx *sqlx.Tx) error
+
+const goMigrationSuffix = ".go"
+
+type goMigration struct {
+	filename string
+	fn       GoMigrationFunc
+}
+
+var (
+	goMigrationMut sync.Mutex
+	goMigrations   = map[string]map[int64]goMigration{} // by the cleaned migration directory
+)
+
+// RegisterGoMigration registers a migration function with the specified version number,
+// to be run together with the migration files in migrationDir, which is the same directory passed to MigrateUp.
+// Migrations of other directories, e.g. of other databases, do not include it.
+// It appears in the migration list as the file '<version>_<title>.go'
+// and must not have the same version number with any of the files.
+// Go migrations can not be reverted. It panics if the registration is invalid, so it should be called in init()
+func RegisterGoMigration(migrationDir string, version int64, title string, fn GoMigrationFunc) {
+	if version <= 0 {
+		panic("dbmigrate: version number must start from 1")
+	}
+	if len(title) == 0 {
+		panic("dbmigrate: go migration title must not be empty")
+	}
+	if fn == nil {
+		panic("dbmigrate: go migration function must not be nil")
+	}
+
+	goMigrationMut.Lock()
+	defer goMigrationMut.Unlock()
+
+	dir := path.Clean(migrationDir)
+	dirMigrations, ok := goMigrations[dir]
+	if !ok {
+		dirMigrations = map[int64]goMigration{}
+		goMigrations[dir] = dirMigrations
+	}
+
+	if _, existed := dirMigrations[version]; existed {
+		panic(fmt.Sprintf("dbmigrate: duplicated go migration version '%04d' in directory '%s'", version, dir))
+	}
+	dirMigrations[version] = goMigration{
+		filename: fmt.Sprintf("%04d_%s%s", version, title, goMigrationSuffix),
+		fn:       fn,
+	}
+}
+
+// getGoMigrations returns the functions registered for the directory by their filenames
+func getGoMigrations(migrationDir string) map[string]GoMigrationFunc {
+	goMigrationMut.Lock()
+	defer goMigrationMut.Unlock()
+
+	dirMigrations := goMigrations[path.Clean(migrationDir)]
+	result := make(map[string]GoMigrationFunc, len(dirMigrations))
+	for _, m := range dirMigrations {
+		result[m.filename] = m.fn
+	}
+	return result
+}
+
+// goMigrationContent is used in place of the file content, for computing the checksum & planning
+func goMigrationContent(filename string) []byte {
+	return []byte("-- go migration: " + filename)
+}
+
+// goMigrationEntry is the directory entry of a Go migration, to be validated together with the migration files
+type goMigrationEntry struct {
+	name string
+}
+
+var _ fs.DirEntry = goMigrationEntry{}
+
+func (e goMigrationEntry) Name() string {
+	return e.name
+}
+
+func (e goMigrationEntry) IsDir() bool {
+	return false
+}
+
+func (e goMigrationEntry) Type() fs.FileMode {
+	return 0
+}
+
+func (e goMigrationEntry) Info() (fs.FileInfo, error) {
+	return nil, fmt.Errorf("go migration '%s' does not have file info", e.name)
+}
+
+func appendGoMigrationEntries(entries []fs.DirEntry, goFuncs map[string]GoMigrationFunc) []fs.DirEntry {
+	for filename := range goFuncs {
+		entries = append(entries, goMigrationEntry{name: filename})
+	}
+	return entries
+}
@@ -0,0 +1,189 @@
+package dbmigrate
+
+import (
+	"context"
+	"errors"
+	"path"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/QuangTung97/dbc/null"
+)
+
+func registerGoMigrationTest(t *testing.T, migrationDir string, version int64, title string, fn GoMigrationFunc) {
+	RegisterGoMigration(migrationDir, version, title, fn)
+	t.Cleanup(func() {
+		goMigrationMut.Lock()
+		defer goMigrationMut.Unlock()
+		delete(goMigrations[path.Clean(migrationDir)], version)
+	})
+}
+
+func TestRegisterGoMigration(t *testing.T) {
+	fn := func(ctx context.Context, tx *sqlx.Tx) error { return nil }
+
+	t.Run("normal", func(t *testing.T) {
+		registerGoMigrationTest(t, "migrations/order", 3, "backfill_user", fn)
+
+		goFuncs := getGoMigrations("migrations/order/")
+		assert.Equal(t, 1, len(goFuncs))
+		assert.NotNil(t, goFuncs["0003_backfill_user.go"])
+	})
+
+	t.Run("separated by directory", func(t *testing.T) {
+		registerGoMigrationTest(t, "migrations/order", 3, "backfill_user", fn)
+		registerGoMigrationTest(t, "migrations/billing", 3, "backfill_invoice", fn)
+
+		goFuncs := getGoMigrations("migrations/billing")
+		assert.Equal(t, 1, len(goFuncs))
+		assert.NotNil(t, goFuncs["0003_backfill_invoice.go"])
+
+		assert.Equal(t, 0, len(getGoMigrations("migrations/other")))
+	})
+
+	t.Run("duplicated", func(t *testing.T) {
+		registerGoMigrationTest(t, "migrations/order", 3, "backfill_user", fn)
+
+		assert.PanicsWithValue(t,
+			"dbmigrate: duplicated go migration version '0003' in directory 'migrations/order'",
+			func() {
+				RegisterGoMigration("./migrations/order", 3, "another", fn)
+			},
+		)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		assert.PanicsWithValue(t, "dbmigrate: version number must start from 1", func() {
+			RegisterGoMigration("migrations", 0, "backfill_user", fn)
+		})
+		assert.PanicsWithValue(t, "dbmigrate: go migration title must not be empty", func() {
+			RegisterGoMigration("migrations", 3, "", fn)
+		})
+		assert.PanicsWithValue(t, "dbmigrate: go migration function must not be nil", func() {
+			RegisterGoMigration("migrations", 3, "backfill_user", nil)
+		})
+		assert.Equal(t, 0, len(getGoMigrations("migrations")))
+	})
+}
+
+func TestParseMigrateEntries__Go_Migration(t *testing.T) {
+	fn := func(ctx context.Context, tx *sqlx.Tx) error { return nil }
+
+	entries := []dirEntryTest{
+		{name: "0001_init.sql"},
+		{name: "0002_add_user.sql"},
+	}
+
+	t.Run("normal", func(t *testing.T) {
+		files, err := parseMigrateEntries(appendGoMigrationEntries(
+			toDirEntries(entries),
+			map[string]GoMigrationFunc{"0003_backfill.go": fn},
+		), false)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []migrateFile{
+			{version: 1, filename: "0001_init.sql"},
+			{version: 2, filename: "0002_add_user.sql"},
+			{version: 3, filename: "0003_backfill.go"},
+		}, files)
+	})
+
+	t.Run("duplicated with sql file", func(t *testing.T) {
+		_, err := parseMigrateEntries(appendGoMigrationEntries(
+			toDirEntries(entries),
+			map[string]GoMigrationFunc{"0002_backfill.go": fn},
+		), false)
+		assert.Equal(t, errors.New("duplicated version number '0002'"), err)
+	})
+
+	t.Run("missing version", func(t *testing.T) {
+		_, err := parseMigrateEntries(appendGoMigrationEntries(
+			toDirEntries(entries),
+			map[string]GoMigrationFunc{"0004_backfill.go": fn},
+		), false)
+		assert.Equal(t, errors.New("missing version number '0003'"), err)
+	})
+}
+
+func TestMigrateUp__Go_Migration__Integration(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	registerGoMigrationTest(t, "testdata/migrate01", 3, "backfill_user", func(ctx context.Context, tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(ctx, `INSERT INTO auth_user (id, username, created_at) VALUES (1, 'user01', 0)`)
+		return err
+	})
+
+	result, err := MigrateUpContext(ctx, db, migrate01Dir, "testdata/migrate01", DatabaseSQLite3)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 3, len(result.Applied))
+	assert.Equal(t, "0003_backfill_user.go", result.Applied[2].Filename)
+
+	var usernames []string
+	err = db.SelectContext(ctx, &usernames, `SELECT username FROM auth_user`)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{"user01"}, usernames)
+
+	row, err := getMigrationRow(ctx, db)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, null.New(SchemaMigration{
+		ID:       1,
+		Version:  3,
+		Filename: "0003_backfill_user.go",
+	}), row)
+
+	historyList, err := getMigrationHistory(ctx, db)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 3, len(historyList))
+	assert.Equal(t, checksumOf(goMigrationContent("0003_backfill_user.go")), historyList[2].Checksum)
+
+	// run again
+	result, err = MigrateUpContext(ctx, db, migrate01Dir, "testdata/migrate01", DatabaseSQLite3)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, len(result.Applied))
+}
+
+func TestMigrateUp__Go_Migration_Failed__Integration(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	registerGoMigrationTest(t, "testdata/migrate01", 3, "backfill_user", func(ctx context.Context, tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(ctx, `INSERT INTO auth_user (id, username, created_at) VALUES (1, 'user01', 0)`)
+		if err != nil {
+			return err
+		}
+		return errors.New("backfill error")
+	})
+
+	result, err := MigrateUpContext(ctx, db, migrate01Dir, "testdata/migrate01", DatabaseSQLite3)
+	assert.Equal(t, errors.New("backfill error"), err)
+	assert.Equal(t, 2, len(result.Applied))
+
+	// rolled back together with the migration row
+	var usernames []string
+	err = db.SelectContext(ctx, &usernames, `SELECT username FROM auth_user`)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string(nil), usernames)
+
+	row, err := getMigrationRow(ctx, db)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, null.New(SchemaMigration{
+		ID:       1,
+		Version:  2,
+		Filename: "0002_add_product.sql",
+	}), row)
+}
+
+func TestMigrateUp__Go_Migration_Other_Directory__Integration(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	registerGoMigrationTest(t, "testdata/migrate02", 3, "backfill_user", func(ctx context.Context, tx *sqlx.Tx) error {
+		return errors.New("should not run")
+	})
+
+	result, err := MigrateUpContext(ctx, db, migrate01Dir, "testdata/migrate01", DatabaseSQLite3)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(result.Applied))
+}
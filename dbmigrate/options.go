@@ -0,0 +1,53 @@
+package dbmigrate
+
+import "time"
+
+// DefaultLockTimeout is the maximum duration to wait for other processes to finish migrating
+const DefaultLockTimeout = 5 * time.Minute
+
+type migrateOptions struct {
+	lockTimeout time.Duration
+	versionGaps bool
+	outOfOrder  bool
+}
+
+type MigrateOption func(opts *migrateOptions)
+
+func newMigrateOptions(options []MigrateOption) migrateOptions {
+	opts := migrateOptions{
+		lockTimeout: DefaultLockTimeout,
+	}
+	for _, fn := range options {
+		fn(&opts)
+	}
+	return opts
+}
+
+// WithLockTimeout sets the maximum duration to wait for the migration lock held by other processes.
+// Zero means only trying to acquire the lock once.
+// The locks of MySQL & Postgres are released when the connection is closed, but the lock of SQLite is a row
+// in the table 'schema_migration_lock'. If a process crashed while migrating SQLite, the row is taken over after
+// SQLiteStaleLockTimeout, or it can be deleted manually to recover sooner
+func WithLockTimeout(timeout time.Duration) MigrateOption {
+	return func(opts *migrateOptions) {
+		opts.lockTimeout = timeout
+	}
+}
+
+// WithVersionGaps allows any increasing version numbers instead of 1..N, e.g. timestamps like '20261018120000'.
+// The applied versions are tracked per version in the table 'schema_migration_history',
+// so every migration applied before enabling this option must have its history.
+// Migrations return an error if the version of the table 'schema_migration' is not found in the history
+func WithVersionGaps() MigrateOption {
+	return func(opts *migrateOptions) {
+		opts.versionGaps = true
+	}
+}
+
+// WithOutOfOrder allows applying the pending versions that are lower than the last applied version,
+// e.g. when migrations from several branches are merged. It is only used together with WithVersionGaps
+func WithOutOfOrder() MigrateOption {
+	return func(opts *migrateOptions) {
+		opts.outOfOrder = true
+	}
+}
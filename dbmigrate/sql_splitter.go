@@ -0,0 +1,253 @@
+package dbmigrate
+
+import (
+	"fmt"
+	"strings"
+)
+
+// sqlStatement is a single statement of a migration script, with the line number where it starts
+type sqlStatement struct {
+	query string
+	line  int
+}
+
+const defaultDelimiter = ";"
+
+const delimiterCommand = "DELIMITER"
+
+// sqlSplitter splits a script into statements, skipping the delimiters inside quotes & comments.
+// For MySQL, it also handles backslash escapes, '#' comments and DELIMITER commands.
+// For Postgres, it also handles dollar-quoted strings
+type sqlSplitter struct {
+	script string
+	dbType DatabaseType
+
+	pos       int
+	line      int
+	delimiter string
+
+	// start & startLine are the position of the first token of the current statement, startLine = 0 if not started
+	start     int
+	startLine int
+
+	statements []sqlStatement
+}
+
+func splitStatements(script string, dbType DatabaseType) ([]sqlStatement, error) {
+	s := &sqlSplitter{
+		script:    script,
+		dbType:    dbType,
+		line:      1,
+		delimiter: defaultDelimiter,
+	}
+	if err := s.split(); err != nil {
+		return nil, err
+	}
+	return s.statements, nil
+}
+
+func (s *sqlSplitter) split() error {
+	for s.pos < len(s.script) {
+		c := s.script[s.pos]
+
+		switch {
+		case c == '\n':
+			s.line++
+			s.pos++
+
+		case s.startLine == 0 && s.dbType == DatabaseMySQL && s.isDelimiterCommand():
+			if err := s.readDelimiterCommand(); err != nil {
+				return err
+			}
+
+		case strings.HasPrefix(s.script[s.pos:], s.delimiter):
+			s.endStatement()
+			s.pos += len(s.delimiter)
+
+		case c == '-' && s.hasNext('-'), c == '#' && s.dbType == DatabaseMySQL:
+			s.skipLineComment()
+
+		case c == '/' && s.hasNext('*'):
+			if err := s.skipBlockComment(); err != nil {
+				return err
+			}
+
+		case c == '\'', c == '"', c == '`':
+			s.startStatement()
+			if err := s.skipQuoted(c); err != nil {
+				return err
+			}
+
+		case c == '$' && s.dbType == DatabasePostgres:
+			s.startStatement()
+			if err := s.skipDollarQuoted(); err != nil {
+				return err
+			}
+
+		default:
+			if !isSpace(c) {
+				s.startStatement()
+			}
+			s.pos++
+		}
+	}
+
+	s.endStatement()
+	return nil
+}
+
+func (s *sqlSplitter) hasNext(c byte) bool {
+	return s.pos+1 < len(s.script) && s.script[s.pos+1] == c
+}
+
+func (s *sqlSplitter) startStatement() {
+	if s.startLine > 0 {
+		return
+	}
+	s.start = s.pos
+	s.startLine = s.line
+}
+
+func (s *sqlSplitter) endStatement() {
+	if s.startLine == 0 {
+		return
+	}
+	s.statements = append(s.statements, sqlStatement{
+		query: strings.TrimSpace(s.script[s.start:s.pos]),
+		line:  s.startLine,
+	})
+	s.startLine = 0
+}
+
+func (s *sqlSplitter) isDelimiterCommand() bool {
+	rest := s.script[s.pos:]
+	if len(rest) <= len(delimiterCommand) {
+		return false
+	}
+	return strings.EqualFold(rest[:len(delimiterCommand)], delimiterCommand) && isSpace(rest[len(delimiterCommand)])
+}
+
+// readDelimiterCommand reads the line 'DELIMITER <delimiter>' used for MySQL triggers & procedures
+func (s *sqlSplitter) readDelimiterCommand() error {
+	lineEnd := s.lineEnd()
+	fields := strings.Fields(s.script[s.pos+len(delimiterCommand) : lineEnd])
+	if len(fields) == 0 {
+		return fmt.Errorf("missing delimiter of the DELIMITER command at line %d", s.line)
+	}
+
+	s.delimiter = fields[0]
+	s.pos = lineEnd
+	return nil
+}
+
+func (s *sqlSplitter) lineEnd() int {
+	index := strings.IndexByte(s.script[s.pos:], '\n')
+	if index < 0 {
+		return len(s.script)
+	}
+	return s.pos + index
+}
+
+func (s *sqlSplitter) skipLineComment() {
+	s.pos = s.lineEnd()
+}
+
+func (s *sqlSplitter) skipBlockComment() error {
+	startLine := s.line
+	index := strings.Index(s.script[s.pos+2:], "*/")
+	if index < 0 {
+		return fmt.Errorf("unterminated comment starting at line %d", startLine)
+	}
+
+	end := s.pos + 2 + index + 2
+	s.line += strings.Count(s.script[s.pos:end], "\n")
+	s.pos = end
+	return nil
+}
+
+// skipQuoted skips a string or a quoted identifier, a doubled quote character is an escaped quote
+func (s *sqlSplitter) skipQuoted(quote byte) error {
+	startLine := s.line
+	s.pos++
+
+	for s.pos < len(s.script) {
+		c := s.script[s.pos]
+
+		switch {
+		case c == '\n':
+			s.line++
+			s.pos++
+
+		case c == '\\' && quote != '`' && s.dbType == DatabaseMySQL:
+			if s.hasNext('\n') {
+				s.line++
+			}
+			s.pos += 2
+
+		case c == quote:
+			if s.hasNext(quote) {
+				s.pos += 2
+				continue
+			}
+			s.pos++
+			return nil
+
+		default:
+			s.pos++
+		}
+	}
+
+	return fmt.Errorf("unterminated quoted string starting at line %d", startLine)
+}
+
+// skipDollarQuoted skips a Postgres string quoted by '$$' or '$tag$'.
+// Other usages of '$' such as the positional parameters '$1' are normal characters
+func (s *sqlSplitter) skipDollarQuoted() error {
+	tag, ok := s.dollarTag()
+	if !ok {
+		s.pos++
+		return nil
+	}
+
+	startLine := s.line
+	bodyStart := s.pos + len(tag)
+	index := strings.Index(s.script[bodyStart:], tag)
+	if index < 0 {
+		return fmt.Errorf("unterminated dollar-quoted string starting at line %d", startLine)
+	}
+
+	end := bodyStart + index + len(tag)
+	s.line += strings.Count(s.script[s.pos:end], "\n")
+	s.pos = end
+	return nil
+}
+
+func (s *sqlSplitter) dollarTag() (string, bool) {
+	// '$' inside an identifier, e.g. 'a$b'
+	if s.pos > 0 && isIdentifierChar(s.script[s.pos-1]) {
+		return "", false
+	}
+
+	for i := s.pos + 1; i < len(s.script); i++ {
+		c := s.script[i]
+		if c == '$' {
+			return s.script[s.pos : i+1], true
+		}
+		if !isIdentifierChar(c) || (i == s.pos+1 && isDigit(c)) {
+			return "", false
+		}
+	}
+	return "", false
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isIdentifierChar(c byte) bool {
+	return c == '_' || isDigit(c) || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
@@ -0,0 +1,207 @@
+package dbmigrate
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		dbType DatabaseType
+		result []sqlStatement
+		err    error
+	}{
+		{
+			name: "normal",
+			script: `
+CREATE TABLE users (id INT);
+
+CREATE TABLE products (
+    id INT
+);
+`,
+			dbType: DatabaseMySQL,
+			result: []sqlStatement{
+				{query: "CREATE TABLE users (id INT)", line: 2},
+				{query: "CREATE TABLE products (\n    id INT\n)", line: 4},
+			},
+		},
+		{
+			name:   "without last delimiter",
+			script: "SELECT 1; SELECT 2",
+			dbType: DatabaseMySQL,
+			result: []sqlStatement{
+				{query: "SELECT 1", line: 1},
+				{query: "SELECT 2", line: 1},
+			},
+		},
+		{
+			name:   "empty statements",
+			script: ";\n  ;\n",
+			dbType: DatabaseMySQL,
+			result: nil,
+		},
+		{
+			name: "comments",
+			script: `-- create users; with comment
+CREATE TABLE users (id INT); # mysql comment;
+/* block comment;
+ * ; */
+INSERT INTO users VALUES (1); -- last;
+`,
+			dbType: DatabaseMySQL,
+			result: []sqlStatement{
+				{query: "CREATE TABLE users (id INT)", line: 2},
+				{query: "INSERT INTO users VALUES (1)", line: 5},
+			},
+		},
+		{
+			name:   "quotes",
+			script: "INSERT INTO `a;b` VALUES ('x;y', \"z;w\", 'it''s;');\nSELECT 1;",
+			dbType: DatabaseMySQL,
+			result: []sqlStatement{
+				{query: "INSERT INTO `a;b` VALUES ('x;y', \"z;w\", 'it''s;')", line: 1},
+				{query: "SELECT 1", line: 2},
+			},
+		},
+		{
+			name:   "mysql backslash escape",
+			script: `INSERT INTO a VALUES ('x\';y'); SELECT 1;`,
+			dbType: DatabaseMySQL,
+			result: []sqlStatement{
+				{query: `INSERT INTO a VALUES ('x\';y')`, line: 1},
+				{query: "SELECT 1", line: 1},
+			},
+		},
+		{
+			name:   "postgres backslash is a normal character",
+			script: `INSERT INTO a VALUES ('C:\'); SELECT 1;`,
+			dbType: DatabasePostgres,
+			result: []sqlStatement{
+				{query: `INSERT INTO a VALUES ('C:\')`, line: 1},
+				{query: "SELECT 1", line: 1},
+			},
+		},
+		{
+			name: "mysql delimiter",
+			script: `CREATE TABLE a (id INT);
+
+DELIMITER $$
+CREATE TRIGGER a_trigger BEFORE INSERT ON a
+FOR EACH ROW
+BEGIN
+    SET NEW.id = NEW.id + 1;
+END$$
+DELIMITER ;
+
+SELECT 1;
+`,
+			dbType: DatabaseMySQL,
+			result: []sqlStatement{
+				{query: "CREATE TABLE a (id INT)", line: 1},
+				{
+					query: "CREATE TRIGGER a_trigger BEFORE INSERT ON a\nFOR EACH ROW\n" +
+						"BEGIN\n    SET NEW.id = NEW.id + 1;\nEND",
+					line: 4,
+				},
+				{query: "SELECT 1", line: 11},
+			},
+		},
+		{
+			name:   "mysql delimiter missing",
+			script: "DELIMITER \nSELECT 1;",
+			dbType: DatabaseMySQL,
+			err:    errors.New("missing delimiter of the DELIMITER command at line 1"),
+		},
+		{
+			name: "postgres dollar quote",
+			script: `CREATE FUNCTION f() RETURNS trigger AS $$
+BEGIN
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+DO $body$ BEGIN PERFORM 1; END $body$;
+SELECT a$b FROM t WHERE id = $1;
+`,
+			dbType: DatabasePostgres,
+			result: []sqlStatement{
+				{
+					query: "CREATE FUNCTION f() RETURNS trigger AS $$\nBEGIN\n    RETURN NEW;\nEND;\n$$ LANGUAGE plpgsql",
+					line:  1,
+				},
+				{query: "DO $body$ BEGIN PERFORM 1; END $body$", line: 6},
+				{query: "SELECT a$b FROM t WHERE id = $1", line: 7},
+			},
+		},
+		{
+			name:   "unterminated quote",
+			script: "SELECT 1;\nSELECT 'abc;",
+			dbType: DatabaseMySQL,
+			err:    errors.New("unterminated quoted string starting at line 2"),
+		},
+		{
+			name:   "unterminated comment",
+			script: "SELECT 1; /* comment",
+			dbType: DatabaseMySQL,
+			err:    errors.New("unterminated comment starting at line 1"),
+		},
+		{
+			name:   "unterminated dollar quote",
+			script: "\nDO $$ BEGIN",
+			dbType: DatabasePostgres,
+			err:    errors.New("unterminated dollar-quoted string starting at line 2"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := splitStatements(tc.script, tc.dbType)
+			assert.Equal(t, tc.err, err)
+			assert.Equal(t, tc.result, result)
+		})
+	}
+}
+
+func TestRunScriptFunc(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/0001_init.sql": &fstest.MapFile{
+			Data: []byte("CREATE TABLE a (id INT);\n\nCREATE TABLE b (id INT);\n"),
+		},
+	}
+
+	t.Run("mysql", func(t *testing.T) {
+		e := &fakeExecutor{}
+		err := runScriptFunc(context.Background(), e, DatabaseMySQL, fsys, "migrations", "0001_init.sql")
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []execInput{
+			{query: "CREATE TABLE a (id INT)"},
+			{query: "CREATE TABLE b (id INT)"},
+		}, e.inputs)
+	})
+
+	t.Run("mysql error", func(t *testing.T) {
+		execErr := errors.New("table existed")
+		e := &fakeExecutor{
+			execErrQuery: "CREATE TABLE b (id INT)",
+			execErr:      execErr,
+		}
+		err := runScriptFunc(context.Background(), e, DatabaseMySQL, fsys, "migrations", "0001_init.sql")
+		assert.Equal(t, "migration file '0001_init.sql' at line 3: table existed", err.Error())
+		assert.Equal(t, true, errors.Is(err, execErr))
+	})
+
+	t.Run("sqlite", func(t *testing.T) {
+		e := &fakeExecutor{}
+		err := runScriptFunc(context.Background(), e, DatabaseSQLite3, fsys, "migrations", "0001_init.sql")
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []execInput{
+			{query: "CREATE TABLE a (id INT);\n\nCREATE TABLE b (id INT);\n"},
+		}, e.inputs)
+	})
+}
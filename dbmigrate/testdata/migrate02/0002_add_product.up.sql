@@ -0,0 +1,5 @@
+CREATE TABLE product
+(
+    id           INTEGER NOT NULL,
+    product_name TEXT    NOT NULL
+) STRICT;
@@ -0,0 +1,6 @@
+CREATE TABLE auth_user
+(
+    id         INTEGER NOT NULL PRIMARY KEY,
+    username   TEXT    NOT NULL,
+    created_at INTEGER NOT NULL
+) STRICT;
\ No newline at end of file
@@ -0,0 +1,2 @@
+CREATE TABLE product (id INTEGER NOT NULL, product_name TEXT NOT NULL) STRICT;
+INSERT INTO not_found_table VALUES (1);
@@ -0,0 +1,211 @@
+package dbmigrate
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"slices"
+)
+
+// gapsState is the migration state when using WithVersionGaps,
+// the applied versions are the versions in the history table instead of 1..currentVersion
+type gapsState struct {
+	files []migrateFile
+
+	// applied is sorted in increasing order
+	applied []int64
+}
+
+func prepareMigrateWithGaps(entries []fs.DirEntry, actions migrateActions) (gapsState, error) {
+	files, err := parseMigrateEntries(entries, true)
+	if err != nil {
+		return gapsState{}, err
+	}
+
+	if err := actions.createTable(); err != nil {
+		return gapsState{}, err
+	}
+
+	lastMigrateRow, err := actions.getRow()
+	if err != nil {
+		return gapsState{}, err
+	}
+	if lastMigrateRow.Valid {
+		if err := checkDirtyRow(lastMigrateRow.Data); err != nil {
+			return gapsState{}, err
+		}
+	}
+
+	historyList, err := actions.getHistory()
+	if err != nil {
+		return gapsState{}, err
+	}
+
+	state := gapsState{
+		files: files,
+	}
+	for _, history := range historyList {
+		if _, ok := findFile(files, history.Version); !ok {
+			return gapsState{}, fmt.Errorf("not found version '%04d' in migration file list", history.Version)
+		}
+		state.applied = append(state.applied, history.Version)
+	}
+	slices.Sort(state.applied)
+
+	// the database was migrated without the history, e.g. before the history table existed.
+	// Continuing would apply all the migration files again
+	if lastMigrateRow.Valid && lastMigrateRow.Data.Version > 0 {
+		if _, ok := slices.BinarySearch(state.applied, lastMigrateRow.Data.Version); !ok {
+			return gapsState{}, fmt.Errorf(
+				"migrated version '%04d' not found in table 'schema_migration_history', "+
+					"its history is required for using version gaps",
+				lastMigrateRow.Data.Version,
+			)
+		}
+	}
+
+	if err := verifyChecksums(files, historyList, actions); err != nil {
+		return gapsState{}, err
+	}
+
+	return state, nil
+}
+
+func (s gapsState) lastApplied() int64 {
+	if len(s.applied) == 0 {
+		return 0
+	}
+	return s.applied[len(s.applied)-1]
+}
+
+func (s gapsState) pendingFiles() []migrateFile {
+	var result []migrateFile
+	for _, file := range s.files {
+		if _, applied := slices.BinarySearch(s.applied, file.version); !applied {
+			result = append(result, file)
+		}
+	}
+	return result
+}
+
+// applySteps returns the steps for applying the pending files with versions not greater than targetVersion.
+// The migration row always keeps the highest applied version, even when a lower version is applied out of order
+func (s gapsState) applySteps(targetVersion int64, actions migrateActions) ([]migrateStep, error) {
+	lastApplied := s.lastApplied()
+	currentVersion := lastApplied
+
+	var steps []migrateStep
+	for _, file := range s.pendingFiles() {
+		if file.version > targetVersion {
+			break
+		}
+
+		if file.version < lastApplied && !actions.outOfOrder {
+			return nil, fmt.Errorf(
+				"migration file '%s' has a version lower than the last applied version '%04d', "+
+					"use WithOutOfOrder to apply it",
+				file.filename, lastApplied,
+			)
+		}
+
+		currentVersion = max(currentVersion, file.version)
+		steps = append(steps, migrateStep{
+			file:     file,
+			cleanRow: newCleanRow(s.files, currentVersion),
+		})
+	}
+	return steps, nil
+}
+
+// revertSteps returns the steps for reverting the last 'numSteps' applied versions, in decreasing order
+func (s gapsState) revertSteps(numSteps int) []migrateStep {
+	var steps []migrateStep
+	for index := len(s.applied) - 1; index >= len(s.applied)-numSteps; index-- {
+		file, _ := findFile(s.files, s.applied[index])
+
+		var prevVersion int64
+		if index > 0 {
+			prevVersion = s.applied[index-1]
+		}
+
+		steps = append(steps, migrateStep{
+			file:     file,
+			cleanRow: newCleanRow(s.files, prevVersion),
+		})
+	}
+	return steps
+}
+
+func doMigrateUpWithGaps(ctx context.Context, entries []fs.DirEntry, actions migrateActions) (MigrateResult, error) {
+	state, err := prepareMigrateWithGaps(entries, actions)
+	if err != nil {
+		return MigrateResult{}, err
+	}
+
+	steps, err := state.applySteps(state.files[len(state.files)-1].version, actions)
+	if err != nil {
+		return MigrateResult{}, err
+	}
+	return runApplySteps(ctx, steps, actions)
+}
+
+func doMigrateDownWithGaps(
+	ctx context.Context, entries []fs.DirEntry, actions migrateActions, steps int,
+) (MigrateResult, error) {
+	state, err := prepareMigrateWithGaps(entries, actions)
+	if err != nil {
+		return MigrateResult{}, err
+	}
+
+	if steps > len(state.applied) {
+		return MigrateResult{}, fmt.Errorf(
+			"can not migrate down %d steps from version '%04d'", steps, state.lastApplied(),
+		)
+	}
+	return runRevertSteps(ctx, state.revertSteps(steps), actions)
+}
+
+// doMigrateToWithGaps reverts the applied versions greater than the target version,
+// then applies the pending versions not greater than the target version
+func doMigrateToWithGaps(
+	ctx context.Context, entries []fs.DirEntry, actions migrateActions, version int64,
+) (MigrateResult, error) {
+	state, err := prepareMigrateWithGaps(entries, actions)
+	if err != nil {
+		return MigrateResult{}, err
+	}
+
+	if _, ok := findFile(state.files, version); version != 0 && !ok {
+		return MigrateResult{}, fmt.Errorf("not found version '%04d' in migration file list", version)
+	}
+
+	numRevert := 0
+	for _, applied := range state.applied {
+		if applied > version {
+			numRevert++
+		}
+	}
+	revertSteps := state.revertSteps(numRevert)
+
+	remaining := gapsState{
+		files:   state.files,
+		applied: state.applied[:len(state.applied)-numRevert],
+	}
+	applySteps, err := remaining.applySteps(version, actions)
+	if err != nil {
+		return MigrateResult{}, err
+	}
+
+	if len(revertSteps) == 0 {
+		return runApplySteps(ctx, applySteps, actions)
+	}
+
+	result, err := runRevertSteps(ctx, revertSteps, actions)
+	if err != nil || len(applySteps) == 0 {
+		return result, err
+	}
+
+	applyResult, err := runApplySteps(ctx, applySteps, actions)
+	result.Applied = append(result.Applied, applyResult.Applied...)
+	return result, err
+}
@@ -0,0 +1,474 @@
+package dbmigrate
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/QuangTung97/dbc/null"
+)
+
+func newGapsHistory(version int64, filename string) MigrationHistory {
+	return MigrationHistory{
+		Version:  version,
+		Filename: filename,
+		Checksum: testChecksum("content of " + filename),
+	}
+}
+
+func TestDoMigrateUp__Version_Gaps(t *testing.T) {
+	entries := []dirEntryTest{
+		{name: "20261018120000_add_index.sql"},
+		{name: "0001_init.sql"},
+		{name: "0005_add_user.sql"},
+	}
+
+	t.Run("from empty", func(t *testing.T) {
+		m := newMigrateTest()
+		m.versionGaps = true
+
+		result, err := doMigrateUp(context.Background(), toDirEntries(entries), m.newActions())
+		assert.Equal(t, nil, err)
+		assert.Equal(t, 3, len(result.Applied))
+
+		assert.Equal(t, []string{
+			"0001_init.sql",
+			"0005_add_user.sql",
+			"20261018120000_add_index.sql",
+		}, m.runScriptInputs)
+		assert.Equal(t, []SchemaMigration{
+			{ID: 1, Version: 1, Filename: "0001_init.sql", IsDirty: true},
+			{ID: 1, Version: 1, Filename: "0001_init.sql"},
+			{ID: 1, Version: 5, Filename: "0005_add_user.sql", IsDirty: true},
+			{ID: 1, Version: 5, Filename: "0005_add_user.sql"},
+			{ID: 1, Version: 20261018120000, Filename: "20261018120000_add_index.sql", IsDirty: true},
+			{ID: 1, Version: 20261018120000, Filename: "20261018120000_add_index.sql"},
+		}, m.upsertInputs)
+		assert.Equal(t, []MigrationHistory{
+			newGapsHistory(1, "0001_init.sql"),
+			newGapsHistory(5, "0005_add_user.sql"),
+			newGapsHistory(20261018120000, "20261018120000_add_index.sql"),
+		}, m.historyInputs)
+	})
+
+	t.Run("version gaps not enabled", func(t *testing.T) {
+		m := newMigrateTest()
+
+		_, err := doMigrateUp(context.Background(), toDirEntries(entries), m.newActions())
+		assert.Equal(t, errors.New("missing version number '0002'"), err)
+	})
+
+	t.Run("out of order not allowed", func(t *testing.T) {
+		m := newMigrateTest()
+		m.versionGaps = true
+		m.getRowValue = null.New(SchemaMigration{
+			ID: 1, Version: 20261018120000, Filename: "20261018120000_add_index.sql",
+		})
+		m.historyList = []MigrationHistory{
+			newGapsHistory(1, "0001_init.sql"),
+			newGapsHistory(20261018120000, "20261018120000_add_index.sql"),
+		}
+
+		result, err := doMigrateUp(context.Background(), toDirEntries(entries), m.newActions())
+		assert.Equal(t, errors.New(
+			"migration file '0005_add_user.sql' has a version lower than "+
+				"the last applied version '20261018120000', use WithOutOfOrder to apply it",
+		), err)
+		assert.Equal(t, MigrateResult{}, result)
+		assert.Equal(t, []string(nil), m.actions)
+	})
+
+	t.Run("out of order allowed", func(t *testing.T) {
+		m := newMigrateTest()
+		m.versionGaps = true
+		m.outOfOrder = true
+		m.transactional = true
+		m.historyList = []MigrationHistory{
+			newGapsHistory(1, "0001_init.sql"),
+			newGapsHistory(20261018120000, "20261018120000_add_index.sql"),
+		}
+
+		result, err := doMigrateUp(context.Background(), toDirEntries(entries), m.newActions())
+		assert.Equal(t, nil, err)
+		assert.Equal(t, 1, len(result.Applied))
+
+		assert.Equal(t, []string{"apply_in_tx"}, m.actions)
+		assert.Equal(t, []string{"0005_add_user.sql"}, m.runScriptInputs)
+		// keep the highest applied version
+		assert.Equal(t, []SchemaMigration{
+			{ID: 1, Version: 20261018120000, Filename: "20261018120000_add_index.sql"},
+		}, m.upsertInputs)
+		assert.Equal(t, []MigrationHistory{
+			newGapsHistory(5, "0005_add_user.sql"),
+		}, m.historyInputs)
+	})
+
+	t.Run("applied version not found", func(t *testing.T) {
+		m := newMigrateTest()
+		m.versionGaps = true
+		m.historyList = []MigrationHistory{
+			newGapsHistory(1, "0001_init.sql"),
+			newGapsHistory(3, "0003_other.sql"),
+		}
+
+		_, err := doMigrateUp(context.Background(), toDirEntries(entries), m.newActions())
+		assert.Equal(t, errors.New("not found version '0003' in migration file list"), err)
+	})
+
+	t.Run("migrated without history", func(t *testing.T) {
+		m := newMigrateTest()
+		m.versionGaps = true
+		m.getRowValue = null.New(SchemaMigration{
+			ID: 1, Version: 5, Filename: "0005_add_user.sql",
+		})
+		m.historyList = []MigrationHistory{
+			newGapsHistory(1, "0001_init.sql"),
+		}
+
+		result, err := doMigrateUp(context.Background(), toDirEntries(entries), m.newActions())
+		assert.Equal(t, errors.New(
+			"migrated version '0005' not found in table 'schema_migration_history', "+
+				"its history is required for using version gaps",
+		), err)
+		assert.Equal(t, MigrateResult{}, result)
+		assert.Equal(t, []string(nil), m.runScriptInputs)
+	})
+
+	t.Run("all reverted", func(t *testing.T) {
+		m := newMigrateTest()
+		m.versionGaps = true
+		m.getRowValue = null.New(SchemaMigration{ID: 1, Version: 0})
+
+		result, err := doMigrateUp(context.Background(), toDirEntries(entries), m.newActions())
+		assert.Equal(t, nil, err)
+		assert.Equal(t, 3, len(result.Applied))
+	})
+
+	t.Run("dirty", func(t *testing.T) {
+		m := newMigrateTest()
+		m.versionGaps = true
+		m.getRowValue = null.New(SchemaMigration{
+			ID: 1, Version: 5, Filename: "0005_add_user.sql", IsDirty: true,
+		})
+
+		_, err := doMigrateUp(context.Background(), toDirEntries(entries), m.newActions())
+		assert.Equal(t, errors.New(
+			"database is in dirty state at version '0005' with file '0005_add_user.sql', "+
+				"repair it manually then use Force to set the version",
+		), err)
+	})
+
+	t.Run("checksum changed", func(t *testing.T) {
+		m := newMigrateTest()
+		m.versionGaps = true
+		m.historyList = []MigrationHistory{
+			{Version: 5, Filename: "0005_add_user.sql", Checksum: "old"},
+		}
+
+		_, err := doMigrateUp(context.Background(), toDirEntries(entries), m.newActions())
+		assert.Equal(t, errors.New(
+			"content of the applied migration file '0005_add_user.sql' has changed, "+
+				"expected checksum 'old', got '"+testChecksum("content of 0005_add_user.sql")+"'",
+		), err)
+	})
+}
+
+func TestDoMigrateDown__Version_Gaps(t *testing.T) {
+	entries := []dirEntryTest{
+		{name: "0001_init.up.sql"},
+		{name: "0001_init.down.sql"},
+		{name: "0005_add_user.up.sql"},
+		{name: "0005_add_user.down.sql"},
+		{name: "0009_add_index.up.sql"},
+		{name: "0009_add_index.down.sql"},
+	}
+
+	t.Run("normal", func(t *testing.T) {
+		m := newMigrateTest()
+		m.versionGaps = true
+		m.historyList = []MigrationHistory{
+			newGapsHistory(9, "0009_add_index.up.sql"),
+			newGapsHistory(1, "0001_init.up.sql"),
+		}
+
+		result, err := doMigrateDown(context.Background(), toDirEntries(entries), m.newActions(), 2)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, 2, len(result.Applied))
+
+		assert.Equal(t, []string{"0009_add_index.down.sql", "0001_init.down.sql"}, m.runScriptInputs)
+		assert.Equal(t, []SchemaMigration{
+			{ID: 1, Version: 9, Filename: "0009_add_index.down.sql", IsDirty: true},
+			{ID: 1, Version: 1, Filename: "0001_init.up.sql"},
+			{ID: 1, Version: 1, Filename: "0001_init.down.sql", IsDirty: true},
+			{ID: 1},
+		}, m.upsertInputs)
+		assert.Equal(t, []int64{9, 1}, m.deleteInputs)
+	})
+
+	t.Run("too many steps", func(t *testing.T) {
+		m := newMigrateTest()
+		m.versionGaps = true
+		m.historyList = []MigrationHistory{
+			newGapsHistory(5, "0005_add_user.up.sql"),
+		}
+
+		_, err := doMigrateDown(context.Background(), toDirEntries(entries), m.newActions(), 2)
+		assert.Equal(t, errors.New("can not migrate down 2 steps from version '0005'"), err)
+	})
+}
+
+func TestDoMigrateTo__Version_Gaps(t *testing.T) {
+	entries := []dirEntryTest{
+		{name: "0001_init.up.sql"},
+		{name: "0001_init.down.sql"},
+		{name: "0005_add_user.up.sql"},
+		{name: "0005_add_user.down.sql"},
+		{name: "0009_add_index.up.sql"},
+		{name: "0009_add_index.down.sql"},
+	}
+
+	t.Run("revert then apply", func(t *testing.T) {
+		m := newMigrateTest()
+		m.versionGaps = true
+		m.transactional = true
+		m.historyList = []MigrationHistory{
+			newGapsHistory(1, "0001_init.up.sql"),
+			newGapsHistory(9, "0009_add_index.up.sql"),
+		}
+
+		result, err := doMigrateTo(context.Background(), toDirEntries(entries), m.newActions(), 5)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []AppliedMigration{
+			{Version: 9, Filename: "0009_add_index.down.sql", IsDown: true, Duration: time.Second},
+			{Version: 5, Filename: "0005_add_user.up.sql", Duration: time.Second},
+		}, result.Applied)
+
+		assert.Equal(t, []string{"revert_in_tx", "apply_in_tx"}, m.actions)
+		assert.Equal(t, []SchemaMigration{
+			{ID: 1, Version: 1, Filename: "0001_init.up.sql"},
+			{ID: 1, Version: 5, Filename: "0005_add_user.up.sql"},
+		}, m.upsertInputs)
+	})
+
+	t.Run("version zero", func(t *testing.T) {
+		m := newMigrateTest()
+		m.versionGaps = true
+		m.historyList = []MigrationHistory{
+			newGapsHistory(1, "0001_init.up.sql"),
+			newGapsHistory(5, "0005_add_user.up.sql"),
+		}
+
+		result, err := doMigrateTo(context.Background(), toDirEntries(entries), m.newActions(), 0)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, 2, len(result.Applied))
+		assert.Equal(t, []int64{5, 1}, m.deleteInputs)
+	})
+
+	t.Run("version not found", func(t *testing.T) {
+		m := newMigrateTest()
+		m.versionGaps = true
+
+		_, err := doMigrateTo(context.Background(), toDirEntries(entries), m.newActions(), 3)
+		assert.Equal(t, errors.New("not found version '0003' in migration file list"), err)
+	})
+}
+
+func TestDoStatus_And_Plan__Version_Gaps(t *testing.T) {
+	entries := []dirEntryTest{
+		{name: "0001_init.sql"},
+		{name: "0005_add_user.sql"},
+		{name: "0009_add_index.sql"},
+	}
+
+	m := newMigrateTest()
+	m.versionGaps = true
+	m.outOfOrder = true
+	m.getRowValue = null.New(SchemaMigration{
+		ID: 1, Version: 9, Filename: "0009_add_index.sql",
+	})
+	m.historyList = []MigrationHistory{
+		newGapsHistory(1, "0001_init.sql"),
+		newGapsHistory(9, "0009_add_index.sql"),
+	}
+
+	status, err := doStatus(toDirEntries(entries), m.newActions())
+	assert.Equal(t, nil, err)
+	assert.Equal(t, MigrationStatus{
+		CurrentVersion:  9,
+		CurrentFilename: "0009_add_index.sql",
+		Pending:         []string{"0005_add_user.sql"},
+	}, status)
+
+	plan, err := doPlan(toDirEntries(entries), m.newActions())
+	assert.Equal(t, nil, err)
+	assert.Equal(t, MigratePlan{
+		CurrentVersion: 9,
+		Pending: []PendingMigration{
+			{Version: 5, Filename: "0005_add_user.sql", Content: []byte("content of 0005_add_user.sql")},
+		},
+	}, plan)
+}
+
+func TestDoForce__Version_Gaps(t *testing.T) {
+	entries := []dirEntryTest{
+		{name: "0001_init.sql"},
+		{name: "0005_add_user.sql"},
+	}
+
+	m := newMigrateTest()
+	m.versionGaps = true
+
+	err := doForce(toDirEntries(entries), m.newActions(), 5)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{"mark_applied"}, m.actions)
+	assert.Equal(t, []SchemaMigration{
+		{ID: 1, Version: 5, Filename: "0005_add_user.sql"},
+	}, m.upsertInputs)
+	assert.Equal(t, []MigrationHistory{
+		newGapsHistory(5, "0005_add_user.sql"),
+	}, m.historyInputs)
+
+	err = doForce(toDirEntries(entries), m.newActions(), 3)
+	assert.Equal(t, errors.New("not found version '0003' in migration file list"), err)
+}
+
+func TestDoForce__Version_Gaps__Out_Of_Order(t *testing.T) {
+	entries := []dirEntryTest{
+		{name: "0001_init.sql"},
+		{name: "0003_add_index.sql"},
+		{name: "0005_add_user.sql"},
+	}
+
+	m := newMigrateTest()
+	m.versionGaps = true
+	m.outOfOrder = true
+
+	// 0003 failed after 0005 was applied
+	m.getRowValue = null.New(SchemaMigration{
+		ID: 1, Version: 3, Filename: "0003_add_index.sql", IsDirty: true,
+	})
+	m.historyList = []MigrationHistory{
+		newGapsHistory(1, "0001_init.sql"),
+		newGapsHistory(5, "0005_add_user.sql"),
+	}
+
+	err := doForce(toDirEntries(entries), m.newActions(), 3)
+	assert.Equal(t, nil, err)
+
+	// keeps the history of 0005 & the highest version
+	assert.Equal(t, []string{"mark_applied"}, m.actions)
+	assert.Equal(t, []SchemaMigration{
+		{ID: 1, Version: 5, Filename: "0005_add_user.sql"},
+	}, m.upsertInputs)
+	assert.Equal(t, []MigrationHistory{
+		newGapsHistory(3, "0003_add_index.sql"),
+	}, m.historyInputs)
+	assert.Equal(t, []null.Null[MigrationHistory](nil), m.forceHistory)
+
+	// force version 0 only clears the dirty state
+	m = newMigrateTest()
+	m.versionGaps = true
+	m.historyList = []MigrationHistory{
+		newGapsHistory(1, "0001_init.sql"),
+		newGapsHistory(5, "0005_add_user.sql"),
+	}
+
+	err = doForce(toDirEntries(entries), m.newActions(), 0)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []SchemaMigration{
+		{ID: 1, Version: 5, Filename: "0005_add_user.sql"},
+	}, m.upsertInputs)
+	assert.Equal(t, []MigrationHistory(nil), m.historyInputs)
+}
+
+func TestMigrateUp__Version_Gaps__Integration(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	fsys := fstest.MapFS{
+		"migrations/20261018120000_init.sql": &fstest.MapFile{
+			Data: []byte("CREATE TABLE users (id INTEGER PRIMARY KEY);"),
+		},
+		"migrations/20261018130000_add_product.sql": &fstest.MapFile{
+			Data: []byte("CREATE TABLE product (id INTEGER PRIMARY KEY);"),
+		},
+	}
+
+	result, err := MigrateUpContext(ctx, db, fsys, "migrations", DatabaseSQLite3, WithVersionGaps())
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(result.Applied))
+
+	// merged from another branch
+	fsys["migrations/20261018125000_add_order.sql"] = &fstest.MapFile{
+		Data: []byte("CREATE TABLE orders (id INTEGER PRIMARY KEY);"),
+	}
+
+	_, err = MigrateUpContext(ctx, db, fsys, "migrations", DatabaseSQLite3, WithVersionGaps())
+	assert.Equal(t, errors.New(
+		"migration file '20261018125000_add_order.sql' has a version lower than "+
+			"the last applied version '20261018130000', use WithOutOfOrder to apply it",
+	), err)
+
+	status, err := Status(ctx, db, fsys, "migrations", DatabaseSQLite3, WithVersionGaps())
+	assert.Equal(t, nil, err)
+	assert.Equal(t, MigrationStatus{
+		CurrentVersion:  20261018130000,
+		CurrentFilename: "20261018130000_add_product.sql",
+		Pending:         []string{"20261018125000_add_order.sql"},
+	}, status)
+
+	result, err = MigrateUpContext(ctx, db, fsys, "migrations", DatabaseSQLite3, WithVersionGaps(), WithOutOfOrder())
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(result.Applied))
+	assertTableExist(t, db, "orders")
+
+	status, err = Status(ctx, db, fsys, "migrations", DatabaseSQLite3, WithVersionGaps())
+	assert.Equal(t, nil, err)
+	assert.Equal(t, true, status.UpToDate())
+	assert.Equal(t, int64(20261018130000), status.CurrentVersion)
+}
+
+func TestForce__Version_Gaps__Integration(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	options := []MigrateOption{WithVersionGaps(), WithOutOfOrder()}
+
+	fsys := fstest.MapFS{
+		"migrations/0001_init.sql": &fstest.MapFile{
+			Data: []byte("CREATE TABLE a (id INTEGER PRIMARY KEY);"),
+		},
+		"migrations/0005_add_b.sql": &fstest.MapFile{
+			Data: []byte("CREATE TABLE b (id INTEGER PRIMARY KEY);"),
+		},
+	}
+
+	result, err := MigrateUpContext(ctx, db, fsys, "migrations", DatabaseSQLite3, options...)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(result.Applied))
+
+	// merged from another branch, failed
+	fsys["migrations/0003_add_c.sql"] = &fstest.MapFile{
+		Data: []byte("CREATE TABLE c (id INTEGER PRIMARY KEY); INVALID SQL;"),
+	}
+	_, err = MigrateUpContext(ctx, db, fsys, "migrations", DatabaseSQLite3, options...)
+	assert.NotNil(t, err)
+
+	// repaired manually
+	err = ForceContext(ctx, db, fsys, "migrations", DatabaseSQLite3, 3, options...)
+	assert.Equal(t, nil, err)
+
+	status, err := Status(ctx, db, fsys, "migrations", DatabaseSQLite3, options...)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, MigrationStatus{
+		CurrentVersion:  5,
+		CurrentFilename: "0005_add_b.sql",
+	}, status)
+
+	result, err = MigrateUpContext(ctx, db, fsys, "migrations", DatabaseSQLite3, options...)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, len(result.Applied))
+}
@@ -0,0 +1,19 @@
+package dbc
+
+import "fmt"
+
+// PanicError is returned by Provider.Transact when using PanicPolicyReturnError
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap returns the recovered value if it is an error
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/QuangTung97/dbc/null"
 )
@@ -14,66 +15,254 @@ type DatabaseDialect int
 const (
 	DialectMysql    DatabaseDialect = iota + 1
 	DialectPostgres                 // TODO add test
+	DialectSqlite
 )
 
 type Executor[T TableNamer] struct {
-	dialect DatabaseDialect
-	schema  *Schema[T]
+	dialect   DatabaseDialect
+	schema    *Schema[T]
+	provider  Provider
+	tableName string
+	now       func() time.Time
+
+	plan executorPlan
+}
+
+// executorPlan contains the static statements and the field indexes, computed once in NewExecutor
+type executorPlan struct {
+	primaryKeys    []string
+	primaryIndexes [][]int
+
+	selectQuery      string // SELECT ... FROM table, without the WHERE clause
+	getByIDQuery     string
+	getWithLockQuery string
+
+	insertQuery   string
+	insertIndexes [][]int
+	autoIncIndex  []int // nil when there is no auto increment field
+
+	updateQuery   string  // empty when there is no editable field
+	updateIndexes [][]int // the editable fields followed by the primary keys
+
+	upsertQuery   string
+	upsertIndexes [][]int // all the visible fields, including the auto increment one
+
+	createdAtIndex  []int // nil when there is no SchemaCreatedAt field
+	updatedAtIndex  []int // nil when there is no SchemaUpdatedAt field
+	updatedAtColumn string
+
+	deleteQuery string
+}
+
+type executorOptions struct {
+	provider Provider
+	now      func() time.Time
+}
+
+type ExecutorOption func(opts *executorOptions)
+
+// WithProvider binds the executor to the specified provider.
+// Without it the executor uses the value of the most recent call to any Provider inside the context
+func WithProvider(provider Provider) ExecutorOption {
+	return func(opts *executorOptions) {
+		opts.provider = provider
+	}
+}
+
+// WithClock replaces time.Now for setting the fields of SchemaCreatedAt & SchemaUpdatedAt,
+// mostly used in tests for having deterministic timestamps
+func WithClock(now func() time.Time) ExecutorOption {
+	return func(opts *executorOptions) {
+		opts.now = now
+	}
 }
 
 func NewExecutor[T TableNamer](
-	dialect DatabaseDialect, schema *Schema[T],
+	dialect DatabaseDialect, schema *Schema[T], options ...ExecutorOption,
 ) (*Executor[T], error) {
-	return &Executor[T]{
-		dialect: dialect,
-		schema:  schema,
-	}, nil
-}
+	opts := &executorOptions{
+		now: time.Now,
+	}
+	for _, fn := range options {
+		fn(opts)
+	}
+
+	switch dialect {
+	case DialectMysql, DialectPostgres, DialectSqlite:
+	default:
+		return nil, fmt.Errorf("unsupported database dialect: %v", dialect)
+	}
+	if schema == nil {
+		return nil, fmt.Errorf("schema must not be nil")
+	}
 
-func (e *Executor[T]) getValuesOfEntity(
-	offsetList []fieldOffsetType,
-) func(entityVal reflect.Value) []any {
 	var empty T
-	typ := reflect.TypeOf(empty)
-
-	// build map from offset => field index
-	indexMap := map[fieldOffsetType]int{}
-	for index := range typ.NumField() {
-		field := typ.Field(index)
-		offset := fieldOffsetType(field.Offset)
-		indexMap[offset] = index
-	}
-
-	return func(entityVal reflect.Value) []any {
-		result := make([]any, 0, len(offsetList))
-		for _, offset := range offsetList {
-			index := indexMap[offset]
-			val := entityVal.Field(index).Interface()
-			result = append(result, val)
-		}
-		return result
+	e := &Executor[T]{
+		dialect:   dialect,
+		schema:    schema,
+		provider:  opts.provider,
+		tableName: empty.TableName(),
+		now:       opts.now,
 	}
+	e.plan = e.buildPlan()
+	return e, nil
 }
 
-func (e *Executor[T]) GetByID(ctx context.Context, id T) (null.Null[T], error) {
+func (e *Executor[T]) buildPlan() executorPlan {
+	var plan executorPlan
+
+	var selectCols []string
+	var insertCols []string
+	var updateCols []string
+	var editableCols []string
+
+	for _, offset := range e.schema.allFields {
+		info := e.schema.fieldInfos[offset]
+		index := info.fieldIndex
+
+		if info.isPrimaryKey {
+			plan.primaryKeys = append(plan.primaryKeys, info.dbName)
+			plan.primaryIndexes = append(plan.primaryIndexes, index)
+		}
+
+		switch info.autoTime {
+		case autoTimeCreatedAt:
+			plan.createdAtIndex = index
+		case autoTimeUpdatedAt:
+			plan.updatedAtIndex = index
+			plan.updatedAtColumn = e.quoteIdent(info.dbName)
+		default:
+		}
+
+		if !info.specType.isVisible() {
+			continue
+		}
+		selectCols = append(selectCols, e.quoteIdent(info.dbName))
+		plan.upsertIndexes = append(plan.upsertIndexes, index)
+
+		if info.isAutoInc {
+			plan.autoIncIndex = index
+		} else {
+			insertCols = append(insertCols, e.quoteIdent(info.dbName))
+			plan.insertIndexes = append(plan.insertIndexes, index)
+		}
+
+		if info.specType == fieldSpecEditable {
+			editableCols = append(editableCols, e.quoteIdent(info.dbName))
+			updateCols = append(updateCols, e.quoteIdent(info.dbName)+" = ?")
+			plan.updateIndexes = append(plan.updateIndexes, index)
+		}
+	}
+	plan.updateIndexes = append(plan.updateIndexes, plan.primaryIndexes...)
+
+	quotedTable := e.quoteIdent(e.tableName)
+
 	var buf strings.Builder
-	primaryKeys, primaryOffsets := e.buildSelectQuery(&buf, true)
+	e.buildPrimaryEqualMatchSingle(&buf, plan.primaryKeys)
+	primaryMatch := buf.String()
 
-	e.buildPrimaryEqualMatchSingle(&buf, primaryKeys)
-	args := e.getValuesOfEntity(primaryOffsets)(reflect.ValueOf(id))
+	plan.selectQuery = "SELECT " + strings.Join(selectCols, ", ") + " FROM " + quotedTable
+	plan.getByIDQuery = plan.selectQuery + " WHERE " + primaryMatch
+	plan.getWithLockQuery = plan.getByIDQuery + " FOR UPDATE"
 
-	return NullGet[T](ctx, buf.String(), args...)
+	buf.Reset()
+	buf.WriteString("INSERT INTO ")
+	buf.WriteString(quotedTable)
+	buf.WriteString(" (")
+	buf.WriteString(strings.Join(insertCols, ", "))
+	buf.WriteString(") VALUES ")
+	e.buildPlaceholderLen(&buf, len(insertCols))
+	plan.insertQuery = buf.String()
+
+	if len(updateCols) > 0 {
+		plan.updateQuery = "UPDATE " + quotedTable + " SET " + strings.Join(updateCols, ", ") + " WHERE " + primaryMatch
+	}
+
+	plan.upsertQuery = e.buildUpsertQuery(quotedTable, selectCols, plan.primaryKeys, editableCols)
+
+	plan.deleteQuery = "DELETE FROM " + quotedTable + " WHERE " + primaryMatch
+
+	return plan
 }
 
-func (e *Executor[T]) GetWithLock(ctx context.Context, id T) (null.Null[T], error) {
+func (e *Executor[T]) buildUpsertQuery(
+	quotedTable string, cols []string, primaryKeys []string, editableCols []string,
+) string {
 	var buf strings.Builder
-	primaryKeys, primaryOffsets := e.buildSelectQuery(&buf, true)
+	buf.WriteString("INSERT INTO ")
+	buf.WriteString(quotedTable)
+	buf.WriteString(" (")
+	buf.WriteString(strings.Join(cols, ", "))
+	buf.WriteString(") VALUES ")
+	e.buildPlaceholderLen(&buf, len(cols))
+
+	if e.dialect == DialectMysql {
+		buf.WriteString(" AS new ON DUPLICATE KEY UPDATE ")
+		if len(editableCols) == 0 {
+			// keep the existing row
+			editableCols = []string{e.quoteIdent(primaryKeys[0])}
+		}
+		for index, col := range editableCols {
+			if index > 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(col + " = new." + col)
+		}
+		return buf.String()
+	}
+
+	buf.WriteString(" ON CONFLICT ")
+	e.buildWhereInMultiCols(&buf, primaryKeys)
+	if len(editableCols) == 0 {
+		buf.WriteString(" DO NOTHING")
+		return buf.String()
+	}
 
-	e.buildPrimaryEqualMatchSingle(&buf, primaryKeys)
-	args := e.getValuesOfEntity(primaryOffsets)(reflect.ValueOf(id))
-	buf.WriteString(" FOR UPDATE")
+	buf.WriteString(" DO UPDATE SET ")
+	for index, col := range editableCols {
+		if index > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(col + " = EXCLUDED." + col)
+	}
+	return buf.String()
+}
 
-	return NullGet[T](ctx, buf.String(), args...)
+func (e *Executor[T]) getReadonly(ctx context.Context) Readonly {
+	return GetReadonlyOf(ctx, e.provider)
+}
+
+func (e *Executor[T]) getTx(ctx context.Context) Transaction {
+	return GetTxOf(ctx, e.provider)
+}
+
+func (e *Executor[T]) withOperation(ctx context.Context, operation string) context.Context {
+	return withStatementInfo(ctx, e.tableName, operation)
+}
+
+// withPlanOperation is similar to withOperation, for the queries of executorPlan
+func (e *Executor[T]) withPlanOperation(ctx context.Context, operation string) context.Context {
+	return withStaticStatementInfo(ctx, e.tableName, operation)
+}
+
+func getValuesOfFields(entityVal reflect.Value, indexes [][]int) []any {
+	result := make([]any, 0, len(indexes))
+	for _, index := range indexes {
+		result = append(result, entityVal.FieldByIndex(index).Interface())
+	}
+	return result
+}
+
+func (e *Executor[T]) GetByID(ctx context.Context, id T) (null.Null[T], error) {
+	ctx = e.withPlanOperation(ctx, operationSelect)
+	args := getValuesOfFields(reflect.ValueOf(id), e.plan.primaryIndexes)
+	return nullGetWith[T](ctx, e.getReadonly(ctx), e.plan.getByIDQuery, args...)
+}
+
+func (e *Executor[T]) GetWithLock(ctx context.Context, id T) (null.Null[T], error) {
+	ctx = e.withPlanOperation(ctx, operationSelect)
+	args := getValuesOfFields(reflect.ValueOf(id), e.plan.primaryIndexes)
+	return nullGetWith[T](ctx, e.getReadonly(ctx), e.plan.getWithLockQuery, args...)
 }
 
 func (e *Executor[T]) GetMulti(ctx context.Context, idList []T) ([]T, error) {
@@ -81,12 +270,15 @@ func (e *Executor[T]) GetMulti(ctx context.Context, idList []T) ([]T, error) {
 		return nil, nil
 	}
 
+	ctx = e.withOperation(ctx, operationSelect)
+
 	var buf strings.Builder
-	primaryKeys, primaryOffsets := e.buildSelectQuery(&buf, true)
-	args := e.buildPrimaryEqualMatchMulti(&buf, primaryKeys, primaryOffsets, idList)
+	buf.WriteString(e.plan.selectQuery)
+	buf.WriteString(" WHERE ")
+	args := e.buildPrimaryEqualMatchMulti(&buf, idList)
 
 	// execute
-	tx := GetReadonly(ctx)
+	tx := e.getReadonly(ctx)
 	var result []T
 	err := tx.SelectContext(ctx, &result, buf.String(), args...)
 	return result, err
@@ -106,19 +298,23 @@ func (e *Executor[T]) buildWhereCondFromCond(buf *strings.Builder, cond CondBuil
 }
 
 func (e *Executor[T]) GetCond(ctx context.Context, cond CondBuilderFunc[T]) (null.Null[T], error) {
+	ctx = e.withOperation(ctx, operationSelect)
+
 	var buf strings.Builder
-	e.buildSelectQuery(&buf, false)
+	buf.WriteString(e.plan.selectQuery)
 	args, _ := e.buildWhereCondFromCond(&buf, cond)
-	return NullGet[T](ctx, buf.String(), args...)
+	return nullGetWith[T](ctx, e.getReadonly(ctx), buf.String(), args...)
 }
 
 func (e *Executor[T]) SelectCond(ctx context.Context, cond CondBuilderFunc[T]) ([]T, error) {
+	ctx = e.withOperation(ctx, operationSelect)
+
 	var buf strings.Builder
-	e.buildSelectQuery(&buf, false)
+	buf.WriteString(e.plan.selectQuery)
 	args, _ := e.buildWhereCondFromCond(&buf, cond)
 
 	var result []T
-	err := GetReadonly(ctx).SelectContext(ctx, &result, buf.String(), args...)
+	err := e.getReadonly(ctx).SelectContext(ctx, &result, buf.String(), args...)
 	return result, err
 }
 
@@ -132,9 +328,8 @@ func (e *Executor[T]) buildPrimaryEqualMatchSingle(buf *strings.Builder, primary
 	}
 }
 
-func (e *Executor[T]) buildPrimaryEqualMatchMulti(
-	buf *strings.Builder, primaryKeys []string, primaryOffsets []fieldOffsetType, idList []T,
-) []any {
+func (e *Executor[T]) buildPrimaryEqualMatchMulti(buf *strings.Builder, idList []T) []any {
+	primaryKeys := e.plan.primaryKeys
 	if len(primaryKeys) > 1 {
 		e.buildWhereInMultiCols(buf, primaryKeys)
 		buf.WriteString(" IN ")
@@ -146,49 +341,14 @@ func (e *Executor[T]) buildPrimaryEqualMatchMulti(
 	}
 
 	// build args
-	getFunc := e.getValuesOfEntity(primaryOffsets)
-	args := make([]any, 0, len(primaryOffsets)*len(idList))
+	args := make([]any, 0, len(primaryKeys)*len(idList))
 	for _, id := range idList {
-		args = append(args, getFunc(reflect.ValueOf(id))...)
-	}
-	return args
-}
-
-func (e *Executor[T]) buildSelectQuery(
-	buf *strings.Builder, withWhere bool,
-) ([]string, []fieldOffsetType) {
-	buf.WriteString("SELECT ")
-
-	var primaryKeys []string
-	var primaryOffsets []fieldOffsetType
-	fieldCount := 0
-
-	for _, offset := range e.schema.allFields {
-		info := e.schema.fieldInfos[offset]
-		if info.isPrimaryKey {
-			primaryKeys = append(primaryKeys, info.dbName)
-			primaryOffsets = append(primaryOffsets, offset)
-		}
-
-		if !info.specType.isVisible() {
-			continue
-		}
-
-		fieldCount++
-		if fieldCount > 1 {
-			buf.WriteString(", ")
+		idVal := reflect.ValueOf(id)
+		for _, index := range e.plan.primaryIndexes {
+			args = append(args, idVal.FieldByIndex(index).Interface())
 		}
-		buf.WriteString(e.quoteIdent(info.dbName))
 	}
-
-	buf.WriteString(" FROM ")
-	var emptyValue T
-	buf.WriteString(e.quoteIdent(emptyValue.TableName()))
-	if withWhere {
-		buf.WriteString(" WHERE ")
-	}
-
-	return primaryKeys, primaryOffsets
+	return args
 }
 
 func (e *Executor[T]) buildPlaceholderLen(buf *strings.Builder, size int) {
@@ -234,167 +394,164 @@ func (e *Executor[T]) buildWhereInMultiCols(buf *strings.Builder, cols []string)
 }
 
 func (e *Executor[T]) Insert(ctx context.Context, entity *T) error {
-	var buf strings.Builder
-	buf.WriteString("INSERT INTO ")
-
-	buf.WriteString(e.quoteIdent((*entity).TableName()))
-	buf.WriteString(" (")
+	ctx = e.withPlanOperation(ctx, operationInsert)
 
 	entityVal := reflect.ValueOf(entity).Elem()
-	fieldCount := 0
-	var args []any
-
-	var autoIncField null.Null[reflect.Value]
-
-	for index := range entityVal.NumField() {
-		offset := e.schema.allFields[index]
-		info := e.schema.fieldInfos[offset]
-		if !info.specType.isVisible() {
-			continue
-		}
-		if info.isAutoInc {
-			autoIncField = null.New(entityVal.Field(index))
-			continue
-		}
-
-		fieldCount++
-		if fieldCount > 1 {
-			buf.WriteString(", ")
-		}
-		buf.WriteString(e.quoteIdent(info.dbName))
-
-		val := entityVal.Field(index).Interface()
-		args = append(args, val)
-	}
-
-	buf.WriteString(") VALUES ")
-	e.buildPlaceholderLen(&buf, fieldCount)
+	e.setTimestamps(entityVal)
+	args := getValuesOfFields(entityVal, e.plan.insertIndexes)
 
-	tx := GetTx(ctx)
-	result, err := tx.ExecContext(ctx, buf.String(), args...)
+	tx := e.getTx(ctx)
+	result, err := tx.ExecContext(ctx, e.plan.insertQuery, args...)
 	if err != nil {
 		return err
 	}
 
-	if autoIncField.Valid {
-		val := autoIncField.Data
+	if e.plan.autoIncIndex != nil {
 		insertID, err := result.LastInsertId()
 		if err != nil {
 			return err
 		}
-		val.SetInt(insertID)
+		entityVal.FieldByIndex(e.plan.autoIncIndex).SetInt(insertID)
 	}
 
-	return err
+	return nil
+}
+
+// setTimestamps sets the fields of SchemaCreatedAt & SchemaUpdatedAt to the current time
+func (e *Executor[T]) setTimestamps(entityVal reflect.Value) {
+	if e.plan.createdAtIndex == nil && e.plan.updatedAtIndex == nil {
+		return
+	}
+	now := reflect.ValueOf(e.now())
+	if e.plan.createdAtIndex != nil {
+		entityVal.FieldByIndex(e.plan.createdAtIndex).Set(now)
+	}
+	if e.plan.updatedAtIndex != nil {
+		entityVal.FieldByIndex(e.plan.updatedAtIndex).Set(now)
+	}
 }
 
 // TODO insert multi
 
+// Update writes the editable fields of the entity to the row with the same primary key.
+// The entity is passed by value, so the new value of the field of SchemaUpdatedAt is not visible to the caller
 func (e *Executor[T]) Update(ctx context.Context, entity T) error {
-	var buf strings.Builder
-	buf.WriteString("UPDATE ")
-	buf.WriteString(e.quoteIdent(entity.TableName()))
-	buf.WriteString(" SET ")
-
-	entityVal := reflect.ValueOf(entity)
-	fieldCount := 0
-	var args []any
+	if len(e.plan.updateQuery) == 0 {
+		return fmt.Errorf("type '%s' has no editable field to update", e.schema.typeName())
+	}
 
-	var primaryKeys []string
-	var primaryOffsets []fieldOffsetType
+	ctx = e.withPlanOperation(ctx, operationUpdate)
 
-	for index := range entityVal.NumField() {
-		offset := e.schema.allFields[index]
-		info := e.schema.fieldInfos[offset]
+	entityVal := reflect.ValueOf(&entity).Elem()
+	if e.plan.updatedAtIndex != nil {
+		entityVal.FieldByIndex(e.plan.updatedAtIndex).Set(reflect.ValueOf(e.now()))
+	}
+	args := getValuesOfFields(entityVal, e.plan.updateIndexes)
 
-		if info.isPrimaryKey {
-			primaryKeys = append(primaryKeys, info.dbName)
-			primaryOffsets = append(primaryOffsets, offset)
-		}
+	tx := e.getTx(ctx)
+	_, err := tx.ExecContext(ctx, e.plan.updateQuery, args...)
+	return err
+}
 
-		if info.specType != fieldSpecEditable {
-			continue
-		}
+// UpdateCond sets the columns chosen by the set function on all the rows matching the condition,
+// the column of SchemaUpdatedAt is also set to the current time. Both set & the condition must not be empty
+func (e *Executor[T]) UpdateCond(ctx context.Context, set UpdateBuilderFunc[T], cond CondBuilderFunc[T]) error {
+	builder, table := newUpdateBuilder(e.schema, e.dialect)
+	set(builder, table)
+	if builder.err != nil {
+		return builder.err
+	}
+	if len(builder.setList) == 0 {
+		return fmt.Errorf("update set must not be empty")
+	}
 
-		fieldCount++
-		if fieldCount > 1 {
-			buf.WriteString(", ")
-		}
-		buf.WriteString(e.quoteIdent(info.dbName))
-		buf.WriteString(" = ?")
-		args = append(args, entityVal.Field(index).Interface())
+	setList, args := builder.setList, builder.args
+	if e.plan.updatedAtIndex != nil {
+		setList = append(setList, e.plan.updatedAtColumn+" = ?")
+		args = append(args, e.now())
 	}
 
-	buf.WriteString(" WHERE ")
-	e.buildPrimaryEqualMatchSingle(&buf, primaryKeys)
-	args = append(args, e.getValuesOfEntity(primaryOffsets)(entityVal)...)
+	var buf strings.Builder
+	buf.WriteString("UPDATE ")
+	buf.WriteString(e.quoteIdent(e.tableName))
+	buf.WriteString(" SET ")
+	buf.WriteString(strings.Join(setList, ", "))
+
+	condArgs, isEmpty := e.buildWhereCondFromCond(&buf, cond)
+	if isEmpty {
+		return fmt.Errorf("update where condition must not be empty")
+	}
+	args = append(args, condArgs...)
 
-	tx := GetTx(ctx)
+	ctx = e.withOperation(ctx, operationUpdate)
+	tx := e.getTx(ctx)
 	_, err := tx.ExecContext(ctx, buf.String(), args...)
 	return err
 }
 
+// Upsert inserts the entity including its primary key, or updates the editable fields if the key already existed.
+// The fields of SchemaCreatedAt & SchemaUpdatedAt of the entity are set to the current time,
+// but only updated_at is written to an existing row.
+// A zero ID of SchemaIDAutoInc means a new row, the entity is inserted the same as Insert
+func (e *Executor[T]) Upsert(ctx context.Context, entity *T) error {
+	entityVal := reflect.ValueOf(entity).Elem()
+	if e.plan.autoIncIndex != nil && entityVal.FieldByIndex(e.plan.autoIncIndex).IsZero() {
+		return e.Insert(ctx, entity)
+	}
+
+	ctx = e.withPlanOperation(ctx, operationInsert)
+
+	e.setTimestamps(entityVal)
+	args := getValuesOfFields(entityVal, e.plan.upsertIndexes)
+
+	tx := e.getTx(ctx)
+	_, err := tx.ExecContext(ctx, e.plan.upsertQuery, args...)
+	return err
+}
+
 // TODO update multi
-// TODO update with condition
-// TODO add insert or update multi
+// TODO add insert or update multi, also sets the fields of SchemaCreatedAt & SchemaUpdatedAt
 
 func (e *Executor[T]) Delete(ctx context.Context, entity T) error {
-	var buf strings.Builder
-	primaryKeys, primaryOffsets := e.buildDeleteQuery(&buf)
-
-	e.buildPrimaryEqualMatchSingle(&buf, primaryKeys)
-	args := e.getValuesOfEntity(primaryOffsets)(reflect.ValueOf(entity))
+	ctx = e.withPlanOperation(ctx, operationDelete)
+	args := getValuesOfFields(reflect.ValueOf(entity), e.plan.primaryIndexes)
 
-	tx := GetTx(ctx)
-	_, err := tx.ExecContext(ctx, buf.String(), args...)
+	tx := e.getTx(ctx)
+	_, err := tx.ExecContext(ctx, e.plan.deleteQuery, args...)
 	return err
 }
 
 func (e *Executor[T]) DeleteMulti(ctx context.Context, idList []T) error {
+	ctx = e.withOperation(ctx, operationDelete)
+
 	var buf strings.Builder
-	primaryKeys, primaryOffsets := e.buildDeleteQuery(&buf)
-	args := e.buildPrimaryEqualMatchMulti(&buf, primaryKeys, primaryOffsets, idList)
+	buf.WriteString("DELETE FROM ")
+	buf.WriteString(e.quoteIdent(e.tableName))
+	buf.WriteString(" WHERE ")
+	args := e.buildPrimaryEqualMatchMulti(&buf, idList)
 
-	tx := GetTx(ctx)
+	tx := e.getTx(ctx)
 	_, err := tx.ExecContext(ctx, buf.String(), args...)
 	return err
 }
 
 func (e *Executor[T]) DeleteCond(ctx context.Context, cond CondBuilderFunc[T]) error {
+	ctx = e.withOperation(ctx, operationDelete)
+
 	var buf strings.Builder
 	buf.WriteString("DELETE FROM ")
-	var empty T
-	buf.WriteString(e.quoteIdent(empty.TableName()))
+	buf.WriteString(e.quoteIdent(e.tableName))
 
 	args, isEmpty := e.buildWhereCondFromCond(&buf, cond)
 	if isEmpty {
 		return fmt.Errorf("delete where condition must not be empty")
 	}
 
-	tx := GetTx(ctx)
+	tx := e.getTx(ctx)
 	_, err := tx.ExecContext(ctx, buf.String(), args...)
 	return err
 }
 
-func (e *Executor[T]) buildDeleteQuery(buf *strings.Builder) ([]string, []fieldOffsetType) {
-	buf.WriteString("DELETE FROM ")
-	var empty T
-	buf.WriteString(e.quoteIdent(empty.TableName()))
-
-	var primaryKeys []string
-	var primaryOffsets []fieldOffsetType
-	for _, offset := range e.schema.allFields {
-		info := e.schema.fieldInfos[offset]
-		if info.isPrimaryKey {
-			primaryKeys = append(primaryKeys, info.dbName)
-			primaryOffsets = append(primaryOffsets, offset)
-		}
-	}
-
-	buf.WriteString(" WHERE ")
-	return primaryKeys, primaryOffsets
-}
-
 func (e *Executor[T]) quoteIdent(name string) string {
 	return quoteIdentWithDialect(e.dialect, name)
 }
@@ -0,0 +1,57 @@
+package dbc
+
+import (
+	"testing"
+)
+
+func BenchmarkExecutor_GetByID(b *testing.B) {
+	e := newExecTest(nil)
+	exec := e.newExec()
+	id := tableTest03{ID: 11}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		e.getQueries = e.getQueries[:0]
+		e.getArgs = e.getArgs[:0]
+		_, _ = exec.GetByID(e.ctx, id)
+	}
+}
+
+func BenchmarkExecutor_Insert(b *testing.B) {
+	e := newExecTest(nil)
+	exec := e.newExec()
+	entity := tableTest03{RoleID: 21, Username: "user01", Age: 31}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		e.execQueries = e.execQueries[:0]
+		e.execArgs = e.execArgs[:0]
+		_ = exec.Insert(e.ctx, &entity)
+	}
+}
+
+func BenchmarkExecutor_Update(b *testing.B) {
+	e := newExecTest(nil)
+	exec := e.newExec()
+	entity := tableTest03{ID: 11, RoleID: 21, Username: "user01", Age: 31}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		e.execQueries = e.execQueries[:0]
+		e.execArgs = e.execArgs[:0]
+		_ = exec.Update(e.ctx, entity)
+	}
+}
+
+func BenchmarkExecutor_Delete(b *testing.B) {
+	e := newExecTest(nil)
+	exec := e.newExec()
+	entity := tableTest03{ID: 11}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		e.execQueries = e.execQueries[:0]
+		e.execArgs = e.execArgs[:0]
+		_ = exec.Delete(e.ctx, entity)
+	}
+}
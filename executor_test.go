@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -565,3 +566,420 @@ func TestExecutor_MySQL__SelectCond(t *testing.T) {
 	assert.Equal(t, 1, len(e.selectArgs))
 	assert.Equal(t, []any{"user02"}, e.selectArgs[0])
 }
+
+func TestNewExecutor__Invalid(t *testing.T) {
+	e := newExecTest(t)
+
+	exec, err := NewExecutor(DatabaseDialect(0), e.schema)
+	assert.Equal(t, errors.New("unsupported database dialect: 0"), err)
+	assert.Nil(t, exec)
+
+	exec, err = NewExecutor[tableTest03](DialectMysql, nil)
+	assert.Equal(t, errors.New("schema must not be nil"), err)
+	assert.Nil(t, exec)
+}
+
+func TestExecutor_MySQL__Update__No_Editable_Field(t *testing.T) {
+	e := newExecTest(t)
+	e.schema = RegisterSchema(func(s *Schema[tableTest03], table *tableTest03) {
+		SchemaIDInt64(s, &table.ID)
+		SchemaConst(s, &table.RoleID)
+		SchemaConst(s, &table.Username)
+		SchemaConst(s, &table.Age)
+		SchemaIgnore(s, &table.CreatedAt)
+		SchemaIgnore(s, &table.UpdatedAt)
+	})
+	exec := e.newExec()
+
+	err := exec.Update(e.ctx, tableTest03{ID: 11})
+	assert.Equal(t, errors.New("type 'dbc.tableTest03' has no editable field to update"), err)
+	assert.Equal(t, 0, len(e.execQueries))
+}
+
+func TestExecutor_SQLite__Embedded_Struct(t *testing.T) {
+	db := newTestDB(t)
+	provider := NewProvider(db)
+	ctx := provider.Autocommit(context.Background())
+
+	schema := RegisterSchema(func(s *Schema[authUserEmbedded], table *authUserEmbedded) {
+		SchemaIDAutoInc(s, &table.ID)
+		SchemaEditable(s, &table.Username)
+		SchemaConst(s, &table.CreatedAt)
+	})
+	exec, err := NewExecutor(DialectSqlite, schema)
+	assert.Equal(t, nil, err)
+
+	newUser := func(username string, createdAt int64) authUserEmbedded {
+		var user authUserEmbedded
+		user.Username = username
+		user.CreatedAt = createdAt
+		return user
+	}
+
+	user01 := newUser("user01", 2001)
+	user02 := newUser("user02", 2002)
+
+	// insert
+	assert.Equal(t, nil, exec.Insert(ctx, &user01))
+	assert.Equal(t, nil, exec.Insert(ctx, &user02))
+	assert.Equal(t, int64(1), user01.ID)
+	assert.Equal(t, int64(2), user02.ID)
+
+	// get
+	result, err := exec.GetByID(ctx, authUserEmbedded{ID: user02.ID})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, null.New(user02), result)
+
+	// update
+	user01.Username = "user01-new"
+	user01.CreatedAt = 3001 // const, not updated
+	assert.Equal(t, nil, exec.Update(ctx, user01))
+
+	users, err := exec.GetMulti(ctx, []authUserEmbedded{{ID: user01.ID}, {ID: user02.ID}})
+	assert.Equal(t, nil, err)
+	expected01 := newUser("user01-new", 2001)
+	expected01.ID = user01.ID
+	assert.Equal(t, []authUserEmbedded{expected01, user02}, users)
+
+	// select with the condition on the embedded field
+	users, err = exec.SelectCond(ctx, func(b *CondBuilder[authUserEmbedded], table *authUserEmbedded) {
+		CondEqual(b, &table.CreatedAt, 2002)
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []authUserEmbedded{user02}, users)
+
+	// delete
+	assert.Equal(t, nil, exec.Delete(ctx, user01))
+	err = exec.DeleteCond(ctx, func(b *CondBuilder[authUserEmbedded], table *authUserEmbedded) {
+		CondEqual(b, &table.Username, "user02")
+	})
+	assert.Equal(t, nil, err)
+
+	users, err = exec.SelectCond(ctx, func(b *CondBuilder[authUserEmbedded], table *authUserEmbedded) {})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []authUserEmbedded(nil), users)
+}
+
+func TestExecutor_MySQL__Timestamps(t *testing.T) {
+	e := newExecTest(t)
+	e.schema = RegisterSchema(func(s *Schema[tableTest03], table *tableTest03) {
+		SchemaIDAutoInc(s, &table.ID)
+		SchemaConst(s, &table.RoleID)
+
+		SchemaEditable(s, &table.Username)
+		SchemaEditable(s, &table.Age)
+
+		SchemaCreatedAt(s, &table.CreatedAt)
+		SchemaUpdatedAt(s, &table.UpdatedAt)
+	})
+
+	now := time.Date(2026, 10, 19, 8, 30, 0, 0, time.UTC)
+	exec, err := NewExecutor(DialectMysql, e.schema, WithClock(func() time.Time { return now }))
+	assert.Equal(t, nil, err)
+
+	entity := tableTest03{
+		RoleID:   21,
+		Username: "user01",
+		Age:      31,
+	}
+
+	// do insert
+	err = exec.Insert(e.ctx, &entity)
+	assert.Equal(t, nil, err)
+
+	assert.Equal(
+		t,
+		joinString(
+			"INSERT INTO `table_test03` (`role_id`, `username`, `age`, `created_at`, `updated_at`)",
+			"VALUES (?, ?, ?, ?, ?)",
+		),
+		e.execQueries[0],
+	)
+	assert.Equal(t, []any{
+		entity.RoleID, entity.Username, entity.Age, now, now,
+	}, e.execArgs[0])
+
+	// fields are set on the entity
+	assert.Equal(t, now, entity.CreatedAt)
+	assert.Equal(t, now, entity.UpdatedAt)
+
+	// do update
+	oldNow := now
+	now = now.Add(time.Hour)
+	entity.Age = 32
+
+	err = exec.Update(e.ctx, entity)
+	assert.Equal(t, nil, err)
+
+	assert.Equal(
+		t,
+		joinString(
+			"UPDATE `table_test03`",
+			"SET `username` = ?, `age` = ?, `updated_at` = ?",
+			"WHERE `id` = ?",
+		),
+		e.execQueries[1],
+	)
+	assert.Equal(t, []any{
+		entity.Username, entity.Age, now,
+		entity.ID,
+	}, e.execArgs[1])
+
+	// update receives a copy of the entity
+	assert.Equal(t, oldNow, entity.UpdatedAt)
+}
+
+func TestExecutor_MySQL__UpdateCond(t *testing.T) {
+	e := newExecTest(t)
+	exec := e.newExec()
+
+	setAge := func(b *UpdateBuilder[tableTest03], table *tableTest03) {
+		UpdateSet(b, &table.Age, 31)
+	}
+	roleCond := func(b *CondBuilder[tableTest03], table *tableTest03) {
+		CondEqual(b, &table.RoleID, testRoleID(32))
+	}
+
+	// do update
+	err := exec.UpdateCond(e.ctx, setAge, roleCond)
+	assert.Equal(t, nil, err)
+
+	// check query
+	assert.Equal(t, 1, len(e.execQueries))
+	assert.Equal(
+		t,
+		joinString(
+			"UPDATE `table_test03`",
+			"SET `age` = ?",
+			"WHERE `role_id` = ?",
+		),
+		e.execQueries[0],
+	)
+
+	// check args
+	assert.Equal(t, []any{31, testRoleID(32)}, e.execArgs[0])
+
+	// no condition
+	err = exec.UpdateCond(e.ctx, setAge, func(b *CondBuilder[tableTest03], table *tableTest03) {})
+	assert.Equal(t, errors.New("update where condition must not be empty"), err)
+
+	// no set
+	err = exec.UpdateCond(e.ctx, func(b *UpdateBuilder[tableTest03], table *tableTest03) {}, roleCond)
+	assert.Equal(t, errors.New("update set must not be empty"), err)
+
+	// not editable
+	err = exec.UpdateCond(e.ctx, func(b *UpdateBuilder[tableTest03], table *tableTest03) {
+		UpdateSet(b, &table.Age, 31)
+		UpdateSet(b, &table.RoleID, testRoleID(33))
+	}, roleCond)
+	assert.Equal(t, errors.New("column 'role_id' of type 'dbc.tableTest03' is not editable"), err)
+
+	// ignored
+	err = exec.UpdateCond(e.ctx, func(b *UpdateBuilder[tableTest03], table *tableTest03) {
+		UpdateSet(b, &table.UpdatedAt, time.Now())
+	}, roleCond)
+	assert.Equal(t, errors.New("column 'updated_at' of type 'dbc.tableTest03' is not editable"), err)
+
+	assert.Equal(t, 1, len(e.execQueries))
+}
+
+func TestExecutor_MySQL__Upsert(t *testing.T) {
+	e := newExecTest(t)
+	exec := e.newExec()
+
+	entity := tableTest03{
+		ID:       11,
+		RoleID:   21,
+		Username: "user01",
+		Age:      31,
+	}
+
+	// do upsert
+	err := exec.Upsert(e.ctx, &entity)
+	assert.Equal(t, nil, err)
+
+	// check query
+	assert.Equal(t, 1, len(e.execQueries))
+	assert.Equal(
+		t,
+		joinString(
+			"INSERT INTO `table_test03` (`id`, `role_id`, `username`, `age`)",
+			"VALUES (?, ?, ?, ?) AS new",
+			"ON DUPLICATE KEY UPDATE `username` = new.`username`, `age` = new.`age`",
+		),
+		e.execQueries[0],
+	)
+
+	// check args
+	assert.Equal(t, []any{
+		entity.ID, entity.RoleID, entity.Username, entity.Age,
+	}, e.execArgs[0])
+
+	// the insert id is not used
+	assert.Equal(t, int64(11), entity.ID)
+}
+
+func TestExecutor_Upsert__Query(t *testing.T) {
+	e := newExecTest(t)
+
+	t.Run("postgres", func(t *testing.T) {
+		exec, err := NewExecutor(DialectPostgres, e.schemaTable4)
+		assert.Equal(t, nil, err)
+		assert.Equal(t,
+			joinString(
+				`INSERT INTO "table_test04" ("role_id", "username", "age", "desc")`,
+				`VALUES (?, ?, ?, ?)`,
+				`ON CONFLICT ("role_id", "username")`,
+				`DO UPDATE SET "age" = EXCLUDED."age", "desc" = EXCLUDED."desc"`,
+			),
+			exec.plan.upsertQuery,
+		)
+	})
+
+	noEditableSchema := RegisterSchema(func(s *Schema[tableTest04], table *tableTest04) {
+		SchemaCompositePrimaryKey(s, &table.RoleID)
+		SchemaCompositePrimaryKey(s, &table.Username)
+		SchemaConst(s, &table.Age)
+		SchemaConst(s, &table.Desc)
+		SchemaIgnore(s, &table.CreatedAt)
+	})
+
+	t.Run("no editable field", func(t *testing.T) {
+		exec, err := NewExecutor(DialectSqlite, noEditableSchema)
+		assert.Equal(t, nil, err)
+		assert.Equal(t,
+			joinString(
+				`INSERT INTO table_test04 (role_id, username, age, desc)`,
+				`VALUES (?, ?, ?, ?)`,
+				`ON CONFLICT (role_id, username) DO NOTHING`,
+			),
+			exec.plan.upsertQuery,
+		)
+	})
+
+	t.Run("no editable field mysql", func(t *testing.T) {
+		exec, err := NewExecutor(DialectMysql, noEditableSchema)
+		assert.Equal(t, nil, err)
+		assert.Equal(t,
+			joinString(
+				"INSERT INTO `table_test04` (`role_id`, `username`, `age`, `desc`)",
+				"VALUES (?, ?, ?, ?) AS new",
+				"ON DUPLICATE KEY UPDATE `role_id` = new.`role_id`",
+			),
+			exec.plan.upsertQuery,
+		)
+	})
+}
+
+func TestExecutor_SQLite__Timestamps(t *testing.T) {
+	db := newTestDB(t)
+	provider := NewProvider(db)
+	ctx := provider.Autocommit(context.Background())
+
+	schema := RegisterSchema(func(s *Schema[tableTest03], table *tableTest03) {
+		SchemaIDAutoInc(s, &table.ID)
+		SchemaConst(s, &table.RoleID)
+
+		SchemaEditable(s, &table.Username)
+		SchemaEditable(s, &table.Age)
+
+		SchemaCreatedAt(s, &table.CreatedAt)
+		SchemaUpdatedAt(s, &table.UpdatedAt)
+	})
+
+	createQuery, err := schema.CreateTableSQL(DialectSqlite)
+	assert.Equal(t, nil, err)
+	_, err = db.Exec(createQuery)
+	assert.Equal(t, nil, err)
+
+	now := time.Date(2026, 10, 19, 8, 30, 0, 0, time.UTC)
+	exec, err := NewExecutor(DialectSqlite, schema, WithClock(func() time.Time { return now }))
+	assert.Equal(t, nil, err)
+
+	getUser := func(id int64) tableTest03 {
+		result, err := exec.GetByID(ctx, tableTest03{ID: id})
+		assert.Equal(t, nil, err)
+		assert.Equal(t, true, result.Valid)
+		return result.Data
+	}
+
+	// insert
+	insertTime := now
+	user01 := tableTest03{RoleID: 21, Username: "user01", Age: 31}
+	assert.Equal(t, nil, exec.Insert(ctx, &user01))
+	assert.Equal(t, tableTest03{
+		ID: 1, RoleID: 21, Username: "user01", Age: 31,
+		CreatedAt: insertTime, UpdatedAt: insertTime,
+	}, getUser(user01.ID))
+
+	// update
+	now = now.Add(time.Hour)
+	updateTime := now
+	user01.Age = 32
+	user01.CreatedAt = now // const, not updated
+	assert.Equal(t, nil, exec.Update(ctx, user01))
+	assert.Equal(t, tableTest03{
+		ID: 1, RoleID: 21, Username: "user01", Age: 32,
+		CreatedAt: insertTime, UpdatedAt: updateTime,
+	}, getUser(user01.ID))
+
+	// upsert a new row
+	now = now.Add(time.Hour)
+	upsertTime := now
+	user02 := tableTest03{ID: 2, RoleID: 22, Username: "user02", Age: 41}
+	assert.Equal(t, nil, exec.Upsert(ctx, &user02))
+	assert.Equal(t, upsertTime, user02.CreatedAt)
+	assert.Equal(t, upsertTime, user02.UpdatedAt)
+	assert.Equal(t, user02, getUser(user02.ID))
+
+	// upsert an existing row, keeps created_at
+	now = now.Add(time.Hour)
+	upsertAgainTime := now
+	user02.Age = 42
+	assert.Equal(t, nil, exec.Upsert(ctx, &user02))
+	assert.Equal(t, tableTest03{
+		ID: 2, RoleID: 22, Username: "user02", Age: 42,
+		CreatedAt: upsertTime, UpdatedAt: upsertAgainTime,
+	}, getUser(user02.ID))
+
+	// upsert new rows without ID
+	user03 := tableTest03{RoleID: 23, Username: "user03", Age: 51}
+	user04 := tableTest03{RoleID: 24, Username: "user04", Age: 61}
+	assert.Equal(t, nil, exec.Upsert(ctx, &user03))
+	assert.Equal(t, nil, exec.Upsert(ctx, &user04))
+	assert.Equal(t, int64(3), user03.ID)
+	assert.Equal(t, int64(4), user04.ID)
+	assert.Equal(t, user03, getUser(user03.ID))
+	assert.Equal(t, user04, getUser(user04.ID))
+
+	// update by condition
+	now = now.Add(time.Hour)
+	updateCondTime := now
+	err = exec.UpdateCond(ctx,
+		func(b *UpdateBuilder[tableTest03], table *tableTest03) {
+			UpdateSet(b, &table.Age, 43)
+		},
+		func(b *CondBuilder[tableTest03], table *tableTest03) {
+			CondEqual(b, &table.RoleID, testRoleID(22))
+		},
+	)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, tableTest03{
+		ID: 2, RoleID: 22, Username: "user02", Age: 43,
+		CreatedAt: upsertTime, UpdatedAt: updateCondTime,
+	}, getUser(user02.ID))
+
+	// the other row is not changed
+	assert.Equal(t, updateTime, getUser(user01.ID).UpdatedAt)
+
+	// updated_at is set automatically
+	err = exec.UpdateCond(ctx,
+		func(b *UpdateBuilder[tableTest03], table *tableTest03) {
+			UpdateSet(b, &table.UpdatedAt, now)
+		},
+		func(b *CondBuilder[tableTest03], table *tableTest03) {
+			CondEqual(b, &table.RoleID, testRoleID(22))
+		},
+	)
+	assert.Equal(t, errors.New("column 'updated_at' of type 'dbc.tableTest03' is set automatically"), err)
+}
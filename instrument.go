@@ -0,0 +1,230 @@
+package dbc
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Attribute is a key value pair attached to spans and metrics.
+// Keys follow the OpenTelemetry semantic conventions for databases
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+const (
+	AttrDBSystem          = "db.system"
+	AttrDBStatement       = "db.statement"
+	AttrDBOperation       = "db.operation"
+	AttrDBTable           = "db.sql.table"
+	AttrErrorType         = "error.type"
+	AttrTransactionStatus = "db.transaction.status"
+)
+
+const (
+	transactionStatusCommit   = "commit"
+	transactionStatusRollback = "rollback"
+)
+
+// Tracer is a minimal subset of the OpenTelemetry tracer,
+// the returned context must contain the new span as the parent of later spans
+type Tracer interface {
+	Start(ctx context.Context, spanName string, attrs []Attribute) (context.Context, Span)
+}
+
+type Span interface {
+	RecordError(err error)
+	End()
+}
+
+// Histogram is a minimal subset of the OpenTelemetry Float64Histogram
+type Histogram interface {
+	Record(ctx context.Context, value float64, attrs []Attribute)
+}
+
+type InstrumentationConfig struct {
+	// DBSystem is the value of the attribute 'db.system', e.g. mysql, postgresql, sqlite
+	DBSystem string
+
+	// Tracer can be nil to disable tracing
+	Tracer Tracer
+
+	// QueryDuration records the latency of every statement in seconds, can be nil
+	QueryDuration Histogram
+
+	// TransactionDuration records the duration of every transaction in seconds, can be nil
+	TransactionDuration Histogram
+}
+
+// Instrumentation creates spans and records metrics for transactions and statements.
+// It implements both QueryHook and TransactionHook, use WithInstrumentation to install it on a Provider
+type Instrumentation struct {
+	conf InstrumentationConfig
+}
+
+var _ QueryHook = &Instrumentation{}
+var _ TransactionHook = &Instrumentation{}
+
+func NewInstrumentation(conf InstrumentationConfig) *Instrumentation {
+	return &Instrumentation{
+		conf: conf,
+	}
+}
+
+func WithInstrumentation(inst *Instrumentation) ProviderOption {
+	return func(opts *providerOptions) {
+		WithTransactionHook(inst)(opts)
+		WithQueryHook(inst)(opts)
+	}
+}
+
+type instrumentSpanKey struct{}
+
+func (i *Instrumentation) startSpan(ctx context.Context, name string, attrs []Attribute) context.Context {
+	if i.conf.Tracer == nil {
+		return ctx
+	}
+	ctx, span := i.conf.Tracer.Start(ctx, name, attrs)
+	return context.WithValue(ctx, instrumentSpanKey{}, span)
+}
+
+func (i *Instrumentation) endSpan(ctx context.Context, err error) {
+	span, ok := ctx.Value(instrumentSpanKey{}).(Span)
+	if !ok {
+		return
+	}
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+func (i *Instrumentation) BeforeTransaction(ctx context.Context) context.Context {
+	return i.startSpan(ctx, "transaction", []Attribute{
+		{Key: AttrDBSystem, Value: i.conf.DBSystem},
+	})
+}
+
+func (i *Instrumentation) AfterTransaction(ctx context.Context, duration time.Duration, err error) {
+	i.endSpan(ctx, err)
+
+	if i.conf.TransactionDuration == nil {
+		return
+	}
+
+	status := transactionStatusCommit
+	if err != nil {
+		status = transactionStatusRollback
+	}
+	i.conf.TransactionDuration.Record(ctx, duration.Seconds(), []Attribute{
+		{Key: AttrDBSystem, Value: i.conf.DBSystem},
+		{Key: AttrTransactionStatus, Value: status},
+	})
+}
+
+func (i *Instrumentation) BeforeQuery(ctx context.Context, query string, _ []any) context.Context {
+	if i.conf.Tracer == nil {
+		return ctx
+	}
+
+	info := getStatementInfo(ctx, query)
+
+	spanName := info.operation
+	if len(info.table) > 0 {
+		spanName += " " + info.table
+	}
+
+	attrs := append(i.statementAttrs(info), Attribute{Key: AttrDBStatement, Value: query})
+	return i.startSpan(ctx, spanName, attrs)
+}
+
+func (i *Instrumentation) AfterQuery(
+	ctx context.Context, query string, _ []any, duration time.Duration, err error,
+) {
+	if errors.Is(err, sql.ErrNoRows) {
+		err = nil
+	}
+
+	i.endSpan(ctx, err)
+
+	if i.conf.QueryDuration == nil {
+		return
+	}
+
+	attrs := i.statementAttrs(getStatementInfo(ctx, query))
+	if err != nil {
+		attrs = append(attrs, Attribute{Key: AttrErrorType, Value: fmt.Sprintf("%T", err)})
+	}
+	i.conf.QueryDuration.Record(ctx, duration.Seconds(), attrs)
+}
+
+func (i *Instrumentation) statementAttrs(info statementInfo) []Attribute {
+	attrs := []Attribute{
+		{Key: AttrDBSystem, Value: i.conf.DBSystem},
+		{Key: AttrDBOperation, Value: info.operation},
+	}
+	if len(info.table) > 0 {
+		attrs = append(attrs, Attribute{Key: AttrDBTable, Value: info.table})
+	}
+	return attrs
+}
+
+// ========================================
+// Statement Info
+// ========================================
+
+const (
+	operationSelect = "SELECT"
+	operationInsert = "INSERT"
+	operationUpdate = "UPDATE"
+	operationDelete = "DELETE"
+)
+
+// statementInfo is set by Executor for hooks to know the table and the operation of a query
+type statementInfo struct {
+	table     string
+	operation string
+
+	// static is true for the queries precomputed in NewExecutor,
+	// which are the only ones prepared by the statement cache
+	static bool
+}
+
+type statementInfoKey struct{}
+
+func withStatementInfo(ctx context.Context, table string, operation string) context.Context {
+	return context.WithValue(ctx, statementInfoKey{}, statementInfo{
+		table:     table,
+		operation: operation,
+	})
+}
+
+// withStaticStatementInfo is similar to withStatementInfo, for the queries precomputed in NewExecutor
+func withStaticStatementInfo(ctx context.Context, table string, operation string) context.Context {
+	return context.WithValue(ctx, statementInfoKey{}, statementInfo{
+		table:     table,
+		operation: operation,
+		static:    true,
+	})
+}
+
+// getStatementInfo returns the info set by Executor,
+// or the first keyword of the query as the operation for other queries
+func getStatementInfo(ctx context.Context, query string) statementInfo {
+	info, ok := ctx.Value(statementInfoKey{}).(statementInfo)
+	if ok {
+		return info
+	}
+
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return statementInfo{}
+	}
+	return statementInfo{
+		operation: strings.ToUpper(fields[0]),
+	}
+}
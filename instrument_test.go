@@ -0,0 +1,266 @@
+package dbc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type instrumentTest struct {
+	recorder *MemoryRecorder
+	provider Provider
+	exec     *Executor[authUser]
+}
+
+func newInstrumentTest(t *testing.T, options ...ProviderOption) *instrumentTest {
+	i := &instrumentTest{}
+	i.recorder = NewMemoryRecorder()
+
+	inst := NewInstrumentation(InstrumentationConfig{
+		DBSystem:            "sqlite",
+		Tracer:              i.recorder,
+		QueryDuration:       i.recorder.Histogram("query"),
+		TransactionDuration: i.recorder.Histogram("transaction"),
+	})
+	options = append(options, WithInstrumentation(inst))
+
+	i.provider = NewProvider(newTestDB(t), options...)
+
+	exec, err := NewExecutor(DialectMysql, newAuthUserSchema(), WithProvider(i.provider))
+	if err != nil {
+		panic(err)
+	}
+	i.exec = exec
+
+	return i
+}
+
+type spanTest struct {
+	name   string
+	attrs  []Attribute
+	parent string
+	errors []error
+	ended  bool
+}
+
+func (i *instrumentTest) getSpans() []spanTest {
+	var result []spanTest
+	for _, span := range i.recorder.Spans() {
+		var parent string
+		if span.Parent != nil {
+			parent = span.Parent.Name
+		}
+		result = append(result, spanTest{
+			name:   span.Name,
+			attrs:  span.Attributes,
+			parent: parent,
+			errors: span.Errors,
+			ended:  span.Ended,
+		})
+	}
+	return result
+}
+
+type metricTest struct {
+	name  string
+	attrs []Attribute
+}
+
+func (i *instrumentTest) getMetrics() []metricTest {
+	var result []metricTest
+	for _, m := range i.recorder.Metrics() {
+		result = append(result, metricTest{
+			name:  m.Name,
+			attrs: m.Attributes,
+		})
+	}
+	return result
+}
+
+func TestInstrumentation__Transact(t *testing.T) {
+	i := newInstrumentTest(t)
+
+	user01 := authUser{
+		Username:  "user01",
+		CreatedAt: 2001,
+	}
+
+	err := i.provider.Transact(context.Background(), func(ctx context.Context) error {
+		if err := i.exec.Insert(ctx, &user01); err != nil {
+			return err
+		}
+		_, err := i.exec.GetByID(ctx, authUser{ID: 2})
+		return err
+	})
+	assert.Equal(t, nil, err)
+
+	insertQuery := "INSERT INTO `auth_user` (`username`, `created_at`) VALUES (?, ?)"
+	selectQuery := "SELECT `id`, `username`, `created_at` FROM `auth_user` WHERE `id` = ?"
+
+	assert.Equal(t, []spanTest{
+		{
+			name: "transaction",
+			attrs: []Attribute{
+				{Key: AttrDBSystem, Value: "sqlite"},
+			},
+			ended: true,
+		},
+		{
+			name: "INSERT auth_user",
+			attrs: []Attribute{
+				{Key: AttrDBSystem, Value: "sqlite"},
+				{Key: AttrDBOperation, Value: "INSERT"},
+				{Key: AttrDBTable, Value: "auth_user"},
+				{Key: AttrDBStatement, Value: insertQuery},
+			},
+			parent: "transaction",
+			ended:  true,
+		},
+		{
+			name: "SELECT auth_user",
+			attrs: []Attribute{
+				{Key: AttrDBSystem, Value: "sqlite"},
+				{Key: AttrDBOperation, Value: "SELECT"},
+				{Key: AttrDBTable, Value: "auth_user"},
+				{Key: AttrDBStatement, Value: selectQuery},
+			},
+			parent: "transaction",
+			ended:  true,
+		},
+	}, i.getSpans())
+
+	assert.Equal(t, []metricTest{
+		{
+			name: "query",
+			attrs: []Attribute{
+				{Key: AttrDBSystem, Value: "sqlite"},
+				{Key: AttrDBOperation, Value: "INSERT"},
+				{Key: AttrDBTable, Value: "auth_user"},
+			},
+		},
+		{
+			name: "query",
+			attrs: []Attribute{
+				{Key: AttrDBSystem, Value: "sqlite"},
+				{Key: AttrDBOperation, Value: "SELECT"},
+				{Key: AttrDBTable, Value: "auth_user"},
+			},
+		},
+		{
+			name: "transaction",
+			attrs: []Attribute{
+				{Key: AttrDBSystem, Value: "sqlite"},
+				{Key: AttrTransactionStatus, Value: "commit"},
+			},
+		},
+	}, i.getMetrics())
+}
+
+func TestInstrumentation__Transact__Rollback(t *testing.T) {
+	i := newInstrumentTest(t)
+
+	txErr := errors.New("some error")
+	err := i.provider.Transact(context.Background(), func(ctx context.Context) error {
+		return txErr
+	})
+	assert.Equal(t, txErr, err)
+
+	assert.Equal(t, []spanTest{
+		{
+			name: "transaction",
+			attrs: []Attribute{
+				{Key: AttrDBSystem, Value: "sqlite"},
+			},
+			errors: []error{txErr},
+			ended:  true,
+		},
+	}, i.getSpans())
+
+	assert.Equal(t, []metricTest{
+		{
+			name: "transaction",
+			attrs: []Attribute{
+				{Key: AttrDBSystem, Value: "sqlite"},
+				{Key: AttrTransactionStatus, Value: "rollback"},
+			},
+		},
+	}, i.getMetrics())
+}
+
+func TestInstrumentation__Transact__Panic(t *testing.T) {
+	i := newInstrumentTest(t, WithPanicLogger(nil))
+
+	assert.PanicsWithValue(t, "some value", func() {
+		_ = i.provider.Transact(context.Background(), func(ctx context.Context) error {
+			panic("some value")
+		})
+	})
+
+	spans := i.getSpans()
+	assert.Equal(t, 1, len(spans))
+	assert.Equal(t, true, spans[0].ended)
+	assert.Equal(t, []error{&PanicError{Value: "some value"}}, spans[0].errors)
+
+	assert.Equal(t, []metricTest{
+		{
+			name: "transaction",
+			attrs: []Attribute{
+				{Key: AttrDBSystem, Value: "sqlite"},
+				{Key: AttrTransactionStatus, Value: "rollback"},
+			},
+		},
+	}, i.getMetrics())
+}
+
+func TestInstrumentation__Raw_Query__With_Error(t *testing.T) {
+	i := newInstrumentTest(t)
+
+	ctx := i.provider.Autocommit(context.Background())
+	_, err := GetTx(ctx).ExecContext(ctx, "insert into not_found (id) values (1)")
+	assert.Equal(t, "no such table: not_found", err.Error())
+
+	spans := i.getSpans()
+	assert.Equal(t, 1, len(spans))
+	assert.Equal(t, "INSERT", spans[0].name)
+	assert.Equal(t, []error{err}, spans[0].errors)
+
+	assert.Equal(t, []metricTest{
+		{
+			name: "query",
+			attrs: []Attribute{
+				{Key: AttrDBSystem, Value: "sqlite"},
+				{Key: AttrDBOperation, Value: "INSERT"},
+				{Key: AttrErrorType, Value: "sqlite3.Error"},
+			},
+		},
+	}, i.getMetrics())
+}
+
+func TestInstrumentation__Not_Found_Is_Not_Error(t *testing.T) {
+	i := newInstrumentTest(t)
+
+	ctx := i.provider.Readonly(context.Background())
+	user, err := i.exec.GetByID(ctx, authUser{ID: 11})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, false, user.Valid)
+
+	spans := i.getSpans()
+	assert.Equal(t, 1, len(spans))
+	assert.Equal(t, []error(nil), spans[0].errors)
+	assert.Equal(t, 3, len(i.getMetrics()[0].attrs))
+}
+
+func TestGetStatementInfo(t *testing.T) {
+	ctx := context.Background()
+
+	assert.Equal(t, statementInfo{operation: "SELECT"}, getStatementInfo(ctx, "\n select * from users"))
+	assert.Equal(t, statementInfo{}, getStatementInfo(ctx, "  "))
+
+	ctx = withStatementInfo(ctx, "users", operationDelete)
+	assert.Equal(t, statementInfo{
+		table:     "users",
+		operation: "DELETE",
+	}, getStatementInfo(ctx, "select * from users"))
+}
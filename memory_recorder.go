@@ -0,0 +1,107 @@
+package dbc
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryRecorder is an in-memory implementation of Tracer and Histogram, for using in tests without a collector
+type MemoryRecorder struct {
+	mut     sync.Mutex
+	spans   []*MemorySpan
+	metrics []MemoryMetric
+}
+
+var _ Tracer = &MemoryRecorder{}
+
+type MemorySpan struct {
+	Name       string
+	Attributes []Attribute
+	Parent     *MemorySpan
+
+	Errors []error
+	Ended  bool
+
+	recorder *MemoryRecorder
+}
+
+var _ Span = &MemorySpan{}
+
+type MemoryMetric struct {
+	Name       string
+	Value      float64
+	Attributes []Attribute
+}
+
+func NewMemoryRecorder() *MemoryRecorder {
+	return &MemoryRecorder{}
+}
+
+type memorySpanKey struct{}
+
+func (r *MemoryRecorder) Start(ctx context.Context, spanName string, attrs []Attribute) (context.Context, Span) {
+	parent, _ := ctx.Value(memorySpanKey{}).(*MemorySpan)
+
+	span := &MemorySpan{
+		Name:       spanName,
+		Attributes: attrs,
+		Parent:     parent,
+		recorder:   r,
+	}
+
+	r.mut.Lock()
+	r.spans = append(r.spans, span)
+	r.mut.Unlock()
+
+	return context.WithValue(ctx, memorySpanKey{}, span), span
+}
+
+func (s *MemorySpan) RecordError(err error) {
+	s.recorder.mut.Lock()
+	defer s.recorder.mut.Unlock()
+	s.Errors = append(s.Errors, err)
+}
+
+func (s *MemorySpan) End() {
+	s.recorder.mut.Lock()
+	defer s.recorder.mut.Unlock()
+	s.Ended = true
+}
+
+// Histogram returns a histogram that records values with the specified metric name
+func (r *MemoryRecorder) Histogram(name string) Histogram {
+	return &memoryHistogram{
+		name:     name,
+		recorder: r,
+	}
+}
+
+type memoryHistogram struct {
+	name     string
+	recorder *MemoryRecorder
+}
+
+func (h *memoryHistogram) Record(_ context.Context, value float64, attrs []Attribute) {
+	h.recorder.mut.Lock()
+	defer h.recorder.mut.Unlock()
+
+	h.recorder.metrics = append(h.recorder.metrics, MemoryMetric{
+		Name:       h.name,
+		Value:      value,
+		Attributes: attrs,
+	})
+}
+
+// Spans returns all started spans, in the order they are started
+func (r *MemoryRecorder) Spans() []*MemorySpan {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	return append([]*MemorySpan(nil), r.spans...)
+}
+
+// Metrics returns all recorded values, in the order they are recorded
+func (r *MemoryRecorder) Metrics() []MemoryMetric {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	return append([]MemoryMetric(nil), r.metrics...)
+}
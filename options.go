@@ -0,0 +1,64 @@
+package dbc
+
+import (
+	"context"
+	"log/slog"
+)
+
+type providerOptions struct {
+	panicPolicy PanicPolicy
+	panicLogger PanicLogger
+	queryHooks  []QueryHook
+	txHooks     []TransactionHook
+
+	stmtCacheSize int
+}
+
+type ProviderOption func(opts *providerOptions)
+
+func newProviderOptions(options []ProviderOption) *providerOptions {
+	opts := &providerOptions{
+		panicPolicy: PanicPolicyRepanic,
+		panicLogger: defaultPanicLogger,
+	}
+	for _, fn := range options {
+		fn(opts)
+	}
+	return opts
+}
+
+// PanicPolicy decides what Provider.Transact does after a panic inside its callback.
+// The transaction is always rolled back first.
+type PanicPolicy int
+
+const (
+	// PanicPolicyRepanic panics again with the recovered value (default)
+	PanicPolicyRepanic PanicPolicy = iota + 1
+
+	// PanicPolicyReturnError converts the panic into a *PanicError
+	PanicPolicyReturnError
+)
+
+// PanicLogger receives every panic recovered by Provider.Transact, including its stack
+type PanicLogger func(ctx context.Context, panicErr *PanicError)
+
+func defaultPanicLogger(ctx context.Context, panicErr *PanicError) {
+	slog.ErrorContext(
+		ctx, "Panic inside dbc.Provider.Transact",
+		slog.Any("panic", panicErr.Value),
+		slog.String("stack", string(panicErr.Stack)),
+	)
+}
+
+func WithPanicPolicy(policy PanicPolicy) ProviderOption {
+	return func(opts *providerOptions) {
+		opts.panicPolicy = policy
+	}
+}
+
+// WithPanicLogger replaces the default slog based logger, nil disables logging
+func WithPanicLogger(logger PanicLogger) ProviderOption {
+	return func(opts *providerOptions) {
+		opts.panicLogger = logger
+	}
+}
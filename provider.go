@@ -4,8 +4,8 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"fmt"
 	"runtime/debug"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 
@@ -37,15 +37,27 @@ var _ Transaction = &sqlx.DB{}
 var _ Transaction = &sqlx.Tx{}
 
 func GetReadonly(ctx context.Context) Readonly {
-	val, ok := getFromContext(ctx)
+	return GetReadonlyOf(ctx, nil)
+}
+
+func GetTx(ctx context.Context) Transaction {
+	return GetTxOf(ctx, nil)
+}
+
+// GetReadonlyOf is similar to GetReadonly, but only looks up the value created by the specified provider.
+// A nil provider means the value of the most recent call to any Provider
+func GetReadonlyOf(ctx context.Context, provider Provider) Readonly {
+	val, ok := getFromContextOf(ctx, provider)
 	if !ok {
 		panic("Missing call to method of dbc.Provider")
 	}
 	return val.tx
 }
 
-func GetTx(ctx context.Context) Transaction {
-	val, ok := getFromContext(ctx)
+// GetTxOf is similar to GetTx, but only looks up the value created by the specified provider.
+// A nil provider means the value of the most recent call to any Provider
+func GetTxOf(ctx context.Context, provider Provider) Transaction {
+	val, ok := getFromContextOf(ctx, provider)
 	if !ok {
 		panic("Missing call to method of dbc.Provider")
 	}
@@ -57,9 +69,42 @@ func GetTx(ctx context.Context) Transaction {
 	return val.tx
 }
 
+// TryGetReadonly is similar to GetReadonly, but returns false instead of panicking
+// when the context is not created by a method of Provider
+func TryGetReadonly(ctx context.Context) (Readonly, bool) {
+	val, ok := getFromContext(ctx)
+	if !ok {
+		return nil, false
+	}
+	return val.tx, true
+}
+
+// TryGetTx is similar to GetTx, but returns false instead of panicking
+// when the context is not created by a method of Provider or is readonly
+func TryGetTx(ctx context.Context) (Transaction, bool) {
+	val, ok := getFromContext(ctx)
+	if !ok || val.isReadonly {
+		return nil, false
+	}
+	return val.tx, true
+}
+
+// InTransaction returns true if the context is inside the callback of Provider.Transact
+func InTransaction(ctx context.Context) bool {
+	val, ok := getFromContext(ctx)
+	if !ok {
+		return false
+	}
+	return val.isTransaction
+}
+
 func NullGet[T any](ctx context.Context, query string, args ...any) (null.Null[T], error) {
+	return nullGetWith[T](ctx, GetReadonly(ctx), query, args...)
+}
+
+func nullGetWith[T any](ctx context.Context, tx Readonly, query string, args ...any) (null.Null[T], error) {
 	var result T
-	err := GetReadonly(ctx).GetContext(ctx, &result, query, args...)
+	err := tx.GetContext(ctx, &result, query, args...)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return null.Null[T]{}, nil
@@ -69,33 +114,84 @@ func NullGet[T any](ctx context.Context, query string, args ...any) (null.Null[T
 	return null.New(result), nil
 }
 
-func NewProvider(db *sqlx.DB) Provider {
+func NewProvider(db *sqlx.DB, options ...ProviderOption) Provider {
+	opts := newProviderOptions(options)
+
+	var cache *stmtCache
+	if opts.stmtCacheSize > 0 {
+		cache = newStmtCache(db, opts.stmtCacheSize)
+	}
+
 	return &providerImpl{
-		db: db,
+		db:        db,
+		hooked:    wrapWithQueryHooks(wrapWithStmtCache(db, cache), opts.queryHooks),
+		opts:      opts,
+		stmtCache: cache,
 	}
 }
 
 type providerImpl struct {
-	db *sqlx.DB
+	db     *sqlx.DB
+	hooked Transaction // db wrapped with the statement cache and query hooks
+	opts   *providerOptions
+
+	stmtCache *stmtCache // nil if not enabled
 }
 
-func (p *providerImpl) Transact(ctx context.Context, fn func(ctx context.Context) error) (err error) {
-	val, ok := getFromContext(ctx)
+func (p *providerImpl) Transact(ctx context.Context, fn func(ctx context.Context) error) error {
+	val, ok := getFromContextOf(ctx, p)
 	if ok && !val.isReadonly {
-		return fn(ctx)
+		// the context may hold the transaction of another provider as the default one, e.g. A -> B -> A
+		return fn(setToContext(ctx, val))
+	}
+
+	if len(p.opts.txHooks) == 0 {
+		return p.doTransact(ctx, fn)
 	}
 
-	// TODO support before transaction and after transaction hook
+	ctx = p.beforeTransaction(ctx)
+	startTime := time.Now()
+
+	defer func() {
+		if r := recover(); r != nil {
+			p.afterTransaction(ctx, startTime, &PanicError{Value: r})
+			panic(r)
+		}
+	}()
+
+	err := p.doTransact(ctx, fn)
+	p.afterTransaction(ctx, startTime, err)
+	return err
+}
+
+func (p *providerImpl) beforeTransaction(ctx context.Context) context.Context {
+	for _, hook := range p.opts.txHooks {
+		ctx = hook.BeforeTransaction(ctx)
+	}
+	return ctx
+}
 
+func (p *providerImpl) afterTransaction(ctx context.Context, startTime time.Time, err error) {
+	duration := time.Since(startTime)
+	for index := len(p.opts.txHooks) - 1; index >= 0; index-- {
+		p.opts.txHooks[index].AfterTransaction(ctx, duration, err)
+	}
+}
+
+func (p *providerImpl) doTransact(ctx context.Context, fn func(ctx context.Context) error) (err error) {
 	tx, err := p.db.BeginTxx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	cachedTx, releaseStmts := wrapTxWithStmtCache(tx, p.stmtCache)
 
 	defer func() {
+		defer releaseStmts()
+
 		if r := recover(); r != nil {
-			debug.PrintStack()
-			err = fmt.Errorf("panic: %v", r)
+			_ = tx.Rollback()
+			err = p.handlePanic(ctx, r, debug.Stack())
+			return
 		}
 		if err != nil {
 			_ = tx.Rollback()
@@ -104,9 +200,11 @@ func (p *providerImpl) Transact(ctx context.Context, fn func(ctx context.Context
 		}
 	}()
 
-	val = &contextValueType{
-		isReadonly: false,
-		tx:         tx,
+	val := &contextValueType{
+		isReadonly:    false,
+		isTransaction: true,
+		tx:            wrapWithQueryHooks(cachedTx, p.opts.queryHooks),
+		provider:      p,
 	}
 	ctx = setToContext(ctx, val)
 
@@ -114,16 +212,34 @@ func (p *providerImpl) Transact(ctx context.Context, fn func(ctx context.Context
 	return err
 }
 
+func (p *providerImpl) handlePanic(ctx context.Context, r any, stack []byte) error {
+	panicErr := &PanicError{
+		Value: r,
+		Stack: stack,
+	}
+
+	if p.opts.panicLogger != nil {
+		p.opts.panicLogger(ctx, panicErr)
+	}
+
+	if p.opts.panicPolicy == PanicPolicyReturnError {
+		return panicErr
+	}
+	panic(r)
+}
+
 func (p *providerImpl) Readonly(ctx context.Context) context.Context {
 	return setToContext(ctx, &contextValueType{
 		isReadonly: true,
-		tx:         p.db,
+		tx:         p.hooked,
+		provider:   p,
 	})
 }
 
 func (p *providerImpl) Autocommit(ctx context.Context) context.Context {
 	return setToContext(ctx, &contextValueType{
 		isReadonly: false,
-		tx:         p.db,
+		tx:         p.hooked,
+		provider:   p,
 	})
 }
@@ -161,7 +161,43 @@ func TestProvider__Transact__With_Error(t *testing.T) {
 
 func TestProvider__Transact__Panic_Inside(t *testing.T) {
 	db := newTestDB(t)
-	provider := NewProvider(db)
+
+	user01 := authUser{
+		Username:  "user01",
+		CreatedAt: 2001,
+	}
+
+	var logged []*PanicError
+
+	provider := NewProvider(db, WithPanicLogger(func(ctx context.Context, panicErr *PanicError) {
+		logged = append(logged, panicErr)
+	}))
+
+	// insert
+	assert.PanicsWithValue(t, "some value", func() {
+		_ = provider.Transact(context.Background(), func(ctx context.Context) error {
+			insertAuthUser(ctx, &user01)
+			panic("some value")
+		})
+	})
+
+	// check logged
+	assert.Equal(t, 1, len(logged))
+	assert.Equal(t, "some value", logged[0].Value)
+	assert.Contains(t, string(logged[0].Stack), "TestProvider__Transact__Panic_Inside")
+
+	// get
+	readCtx := provider.Readonly(context.Background())
+	assert.Equal(t, authUser{}, getAuthUser(readCtx, user01.ID))
+}
+
+func TestProvider__Transact__Panic_Inside__Return_Error(t *testing.T) {
+	db := newTestDB(t)
+	provider := NewProvider(
+		db,
+		WithPanicPolicy(PanicPolicyReturnError),
+		WithPanicLogger(nil),
+	)
 
 	user01 := authUser{
 		Username:  "user01",
@@ -173,13 +209,28 @@ func TestProvider__Transact__Panic_Inside(t *testing.T) {
 		insertAuthUser(ctx, &user01)
 		panic("some value")
 	})
-	assert.Equal(t, errors.New("panic: some value"), err)
+	assert.Equal(t, "panic: some value", err.Error())
+
+	var panicErr *PanicError
+	assert.True(t, errors.As(err, &panicErr))
+	assert.Equal(t, "some value", panicErr.Value)
+	assert.Contains(t, string(panicErr.Stack), "TestProvider__Transact__Panic_Inside__Return_Error")
 
 	// get
 	readCtx := provider.Readonly(context.Background())
 	assert.Equal(t, authUser{}, getAuthUser(readCtx, user01.ID))
 }
 
+func TestPanicError__Unwrap(t *testing.T) {
+	inner := errors.New("inner error")
+	err := &PanicError{Value: inner}
+	assert.Equal(t, "panic: inner error", err.Error())
+	assert.True(t, errors.Is(err, inner))
+
+	err = &PanicError{Value: 12}
+	assert.Equal(t, nil, err.Unwrap())
+}
+
 func TestProvider__Transact_Inside_Transact__Success(t *testing.T) {
 	db := newTestDB(t)
 	provider := NewProvider(db)
@@ -242,3 +293,217 @@ func TestProvider__Transact_Inside_Transact__Rollback(t *testing.T) {
 	assert.Equal(t, authUser{}, getAuthUser(readCtx, user01.ID))
 	assert.Equal(t, authUser{}, getAuthUser(readCtx, user02.ID))
 }
+
+func TestProvider__TryGet(t *testing.T) {
+	db := newTestDB(t)
+	provider := NewProvider(db)
+
+	t.Run("empty context", func(t *testing.T) {
+		ctx := context.Background()
+
+		readonly, ok := TryGetReadonly(ctx)
+		assert.Equal(t, false, ok)
+		assert.Equal(t, nil, readonly)
+
+		tx, ok := TryGetTx(ctx)
+		assert.Equal(t, false, ok)
+		assert.Equal(t, nil, tx)
+
+		assert.Equal(t, false, InTransaction(ctx))
+	})
+
+	t.Run("readonly", func(t *testing.T) {
+		ctx := provider.Readonly(context.Background())
+
+		readonly, ok := TryGetReadonly(ctx)
+		assert.Equal(t, true, ok)
+		assert.Same(t, db, readonly)
+
+		tx, ok := TryGetTx(ctx)
+		assert.Equal(t, false, ok)
+		assert.Equal(t, nil, tx)
+
+		assert.Equal(t, false, InTransaction(ctx))
+	})
+
+	t.Run("autocommit", func(t *testing.T) {
+		ctx := provider.Autocommit(context.Background())
+
+		readonly, ok := TryGetReadonly(ctx)
+		assert.Equal(t, true, ok)
+		assert.Same(t, db, readonly)
+
+		tx, ok := TryGetTx(ctx)
+		assert.Equal(t, true, ok)
+		assert.Same(t, db, tx)
+
+		assert.Equal(t, false, InTransaction(ctx))
+	})
+
+	t.Run("transact", func(t *testing.T) {
+		err := provider.Transact(context.Background(), func(ctx context.Context) error {
+			readonly, ok := TryGetReadonly(ctx)
+			assert.Equal(t, true, ok)
+			assert.Same(t, GetReadonly(ctx), readonly)
+
+			tx, ok := TryGetTx(ctx)
+			assert.Equal(t, true, ok)
+			assert.Same(t, GetTx(ctx), tx)
+
+			assert.Equal(t, true, InTransaction(ctx))
+			return nil
+		})
+		assert.Equal(t, nil, err)
+	})
+}
+
+func (authUser) TableName() string {
+	return "auth_user"
+}
+
+func newAuthUserSchema() *Schema[authUser] {
+	return RegisterSchema(func(s *Schema[authUser], table *authUser) {
+		SchemaIDAutoInc(s, &table.ID)
+		SchemaEditable(s, &table.Username)
+		SchemaConst(s, &table.CreatedAt)
+	})
+}
+
+func TestProvider__Multiple_Databases(t *testing.T) {
+	orderDB := newTestDB(t)
+	billingDB := newTestDB(t)
+
+	orderProvider := NewProvider(orderDB)
+	billingProvider := NewProvider(billingDB)
+
+	schema := newAuthUserSchema()
+
+	orderExec, err := NewExecutor(DialectMysql, schema, WithProvider(orderProvider))
+	assert.Equal(t, nil, err)
+
+	billingExec, err := NewExecutor(DialectMysql, schema, WithProvider(billingProvider))
+	assert.Equal(t, nil, err)
+
+	user01 := authUser{
+		Username:  "user01",
+		CreatedAt: 2001,
+	}
+	user02 := authUser{
+		Username:  "user02",
+		CreatedAt: 2002,
+	}
+	user03 := authUser{
+		Username:  "user03",
+		CreatedAt: 2003,
+	}
+
+	// insert
+	err = orderProvider.Transact(context.Background(), func(ctx context.Context) error {
+		if err := orderExec.Insert(ctx, &user01); err != nil {
+			return err
+		}
+
+		return billingProvider.Transact(ctx, func(ctx context.Context) error {
+			assert.NotSame(t, GetTxOf(ctx, orderProvider), GetTxOf(ctx, billingProvider))
+			assert.Same(t, GetTxOf(ctx, billingProvider), GetTx(ctx))
+
+			if err := billingExec.Insert(ctx, &user02); err != nil {
+				return err
+			}
+			if err := billingExec.Insert(ctx, &user03); err != nil {
+				return err
+			}
+			return orderExec.Update(ctx, authUser{ID: user01.ID, Username: "user01-new"})
+		})
+	})
+	assert.Equal(t, nil, err)
+
+	// get from order db
+	ctx := orderProvider.Readonly(context.Background())
+	users, err := orderExec.SelectCond(ctx, func(b *CondBuilder[authUser], table *authUser) {})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []authUser{
+		{ID: 1, Username: "user01-new", CreatedAt: 2001},
+	}, users)
+
+	// get from billing db
+	ctx = billingProvider.Readonly(ctx)
+	users, err = billingExec.SelectCond(ctx, func(b *CondBuilder[authUser], table *authUser) {})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []authUser{user02, user03}, users)
+
+	// order provider value is still in the context
+	users, err = orderExec.SelectCond(ctx, func(b *CondBuilder[authUser], table *authUser) {})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(users))
+}
+
+func TestProvider__Multiple_Databases__Rollback_Inner(t *testing.T) {
+	orderDB := newTestDB(t)
+	billingDB := newTestDB(t)
+
+	orderProvider := NewProvider(orderDB)
+	billingProvider := NewProvider(billingDB)
+
+	user01 := authUser{
+		Username:  "user01",
+		CreatedAt: 2001,
+	}
+	user02 := authUser{
+		Username:  "user02",
+		CreatedAt: 2002,
+	}
+
+	err := orderProvider.Transact(context.Background(), func(ctx context.Context) error {
+		insertAuthUser(ctx, &user01)
+
+		err := billingProvider.Transact(ctx, func(ctx context.Context) error {
+			insertAuthUser(ctx, &user02)
+			return errors.New("billing error")
+		})
+		assert.Equal(t, errors.New("billing error"), err)
+		return nil
+	})
+	assert.Equal(t, nil, err)
+
+	assert.Equal(t, user01, getAuthUser(orderProvider.Readonly(context.Background()), user01.ID))
+	assert.Equal(t, authUser{}, getAuthUser(billingProvider.Readonly(context.Background()), user02.ID))
+}
+
+func TestProvider__Multiple_Databases__Transact_Again_Inside(t *testing.T) {
+	orderDB := newTestDB(t)
+	billingDB := newTestDB(t)
+
+	orderProvider := NewProvider(orderDB)
+	billingProvider := NewProvider(billingDB)
+
+	user01 := authUser{
+		Username:  "user01",
+		CreatedAt: 2001,
+	}
+	user02 := authUser{
+		Username:  "user02",
+		CreatedAt: 2002,
+	}
+
+	err := orderProvider.Transact(context.Background(), func(ctx context.Context) error {
+		orderTx := GetTx(ctx)
+
+		return billingProvider.Transact(ctx, func(ctx context.Context) error {
+			insertAuthUser(ctx, &user01)
+
+			return orderProvider.Transact(ctx, func(ctx context.Context) error {
+				assert.Same(t, orderTx, GetTx(ctx))
+				assert.NotSame(t, GetTxOf(ctx, billingProvider), GetTx(ctx))
+
+				insertAuthUser(ctx, &user02)
+				return nil
+			})
+		})
+	})
+	assert.Equal(t, nil, err)
+
+	assert.Equal(t, user01, getAuthUser(billingProvider.Readonly(context.Background()), user01.ID))
+	assert.Equal(t, authUser{}, getAuthUser(billingProvider.Readonly(context.Background()), 2))
+	assert.Equal(t, user02, getAuthUser(orderProvider.Readonly(context.Background()), user02.ID))
+}
@@ -0,0 +1,130 @@
+package dbc
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// QueryHook is called around every query executed through the Readonly & Transaction objects of a Provider
+type QueryHook interface {
+	// BeforeQuery is called before executing the query, the returned context will be passed to AfterQuery
+	BeforeQuery(ctx context.Context, query string, args []any) context.Context
+
+	AfterQuery(ctx context.Context, query string, args []any, duration time.Duration, err error)
+}
+
+// WithQueryHook adds a query hook to the provider, hooks are called in the order they are added
+func WithQueryHook(hook QueryHook) ProviderOption {
+	return func(opts *providerOptions) {
+		opts.queryHooks = append(opts.queryHooks, hook)
+	}
+}
+
+// TransactionHook is called around every transaction started by Provider.Transact.
+// Nested calls of Provider.Transact that reuse the transaction do not trigger the hook
+type TransactionHook interface {
+	// BeforeTransaction is called before beginning the transaction,
+	// the returned context is used for the transaction callback and will be passed to AfterTransaction
+	BeforeTransaction(ctx context.Context) context.Context
+
+	// AfterTransaction is called after commit or rollback.
+	// The err is a *PanicError if the callback panicked
+	AfterTransaction(ctx context.Context, duration time.Duration, err error)
+}
+
+// WithTransactionHook adds a transaction hook to the provider, hooks are called in the order they are added
+func WithTransactionHook(hook TransactionHook) ProviderOption {
+	return func(opts *providerOptions) {
+		opts.txHooks = append(opts.txHooks, hook)
+	}
+}
+
+func wrapWithQueryHooks(tx Transaction, hooks []QueryHook) Transaction {
+	if len(hooks) == 0 {
+		return tx
+	}
+	return &hookedTransaction{
+		tx:    tx,
+		hooks: hooks,
+	}
+}
+
+type hookedTransaction struct {
+	tx    Transaction
+	hooks []QueryHook
+}
+
+var _ Transaction = &hookedTransaction{}
+
+func (h *hookedTransaction) before(ctx context.Context, query string, args []any) context.Context {
+	for _, hook := range h.hooks {
+		ctx = hook.BeforeQuery(ctx, query, args)
+	}
+	return ctx
+}
+
+func (h *hookedTransaction) after(
+	ctx context.Context, query string, args []any, startTime time.Time, err error,
+) {
+	duration := time.Since(startTime)
+	for index := len(h.hooks) - 1; index >= 0; index-- {
+		h.hooks[index].AfterQuery(ctx, query, args, duration, err)
+	}
+}
+
+func (h *hookedTransaction) GetContext(ctx context.Context, dest any, query string, args ...any) error {
+	hookCtx := h.before(ctx, query, args)
+	startTime := time.Now()
+	err := h.tx.GetContext(hookCtx, dest, query, args...)
+	h.after(hookCtx, query, args, startTime, err)
+	return err
+}
+
+func (h *hookedTransaction) SelectContext(ctx context.Context, dest any, query string, args ...any) error {
+	hookCtx := h.before(ctx, query, args)
+	startTime := time.Now()
+	err := h.tx.SelectContext(hookCtx, dest, query, args...)
+	h.after(hookCtx, query, args, startTime, err)
+	return err
+}
+
+func (h *hookedTransaction) QueryxContext(ctx context.Context, query string, args ...any) (*sqlx.Rows, error) {
+	hookCtx := h.before(ctx, query, args)
+	startTime := time.Now()
+	rows, err := h.tx.QueryxContext(hookCtx, query, args...)
+	h.after(hookCtx, query, args, startTime, err)
+	return rows, err
+}
+
+func (h *hookedTransaction) Rebind(query string) string {
+	return h.tx.Rebind(query)
+}
+
+func (h *hookedTransaction) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	hookCtx := h.before(ctx, query, args)
+	startTime := time.Now()
+	result, err := h.tx.ExecContext(hookCtx, query, args...)
+	h.after(hookCtx, query, args, startTime, err)
+	return result, err
+}
+
+func (h *hookedTransaction) NamedExecContext(ctx context.Context, query string, arg any) (sql.Result, error) {
+	args := []any{arg}
+	hookCtx := h.before(ctx, query, args)
+	startTime := time.Now()
+	result, err := h.tx.NamedExecContext(hookCtx, query, arg)
+	h.after(hookCtx, query, args, startTime, err)
+	return result, err
+}
+
+func (h *hookedTransaction) NamedQuery(query string, arg any) (*sqlx.Rows, error) {
+	args := []any{arg}
+	hookCtx := h.before(context.Background(), query, args)
+	startTime := time.Now()
+	rows, err := h.tx.NamedQuery(query, arg)
+	h.after(hookCtx, query, args, startTime, err)
+	return rows, err
+}
@@ -0,0 +1,94 @@
+package dbc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type queryHookTest struct {
+	name    string
+	actions *[]string
+
+	queries []string
+	args    [][]any
+	errors  []error
+}
+
+type hookCtxKey struct{}
+
+func (h *queryHookTest) BeforeQuery(ctx context.Context, query string, _ []any) context.Context {
+	*h.actions = append(*h.actions, h.name+":before")
+	return context.WithValue(ctx, hookCtxKey{}, h.name+":"+query)
+}
+
+func (h *queryHookTest) AfterQuery(
+	ctx context.Context, query string, args []any, _ time.Duration, err error,
+) {
+	*h.actions = append(*h.actions, h.name+":after")
+	h.queries = append(h.queries, ctx.Value(hookCtxKey{}).(string))
+	h.args = append(h.args, args)
+	h.errors = append(h.errors, err)
+}
+
+func TestProvider__Query_Hook(t *testing.T) {
+	db := newTestDB(t)
+
+	var actions []string
+	hook1 := &queryHookTest{name: "hook1", actions: &actions}
+	hook2 := &queryHookTest{name: "hook2", actions: &actions}
+
+	provider := NewProvider(db, WithQueryHook(hook1), WithQueryHook(hook2))
+
+	user01 := authUser{
+		Username:  "user01",
+		CreatedAt: 2001,
+	}
+
+	// insert
+	err := provider.Transact(context.Background(), func(ctx context.Context) error {
+		insertAuthUser(ctx, &user01)
+		return nil
+	})
+	assert.Equal(t, nil, err)
+
+	assert.Equal(t, []string{
+		"hook1:before",
+		"hook2:before",
+		"hook2:after",
+		"hook1:after",
+	}, actions)
+
+	insertQuery := `
+INSERT INTO auth_user (username, created_at)
+VALUES (:username, :created_at)
+`
+	assert.Equal(t, []string{"hook2:" + insertQuery}, hook1.queries)
+	assert.Equal(t, [][]any{{&user01}}, hook1.args)
+	assert.Equal(t, []error{nil}, hook1.errors)
+
+	// get
+	actions = nil
+	readCtx := provider.Readonly(context.Background())
+	assert.Equal(t, user01, getAuthUser(readCtx, user01.ID))
+
+	getQuery := `SELECT id, username, created_at FROM auth_user WHERE id = ?`
+	assert.Equal(t, []string{
+		"hook1:before",
+		"hook2:before",
+		"hook2:after",
+		"hook1:after",
+	}, actions)
+	assert.Equal(t, "hook2:"+getQuery, hook1.queries[1])
+	assert.Equal(t, []any{user01.ID}, hook1.args[1])
+
+	// exec with error
+	_, err = GetTx(provider.Autocommit(context.Background())).ExecContext(
+		context.Background(), "INSERT INTO not_found_table (id) VALUES (?)", 12,
+	)
+	assert.Equal(t, "no such table: not_found_table", err.Error())
+	assert.Equal(t, err, hook2.errors[2])
+	assert.Equal(t, []any{12}, hook2.args[2])
+}
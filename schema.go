@@ -2,6 +2,8 @@ package dbc
 
 import (
 	"reflect"
+	"slices"
+	"time"
 	"unsafe"
 )
 
@@ -12,6 +14,8 @@ type Schema[T TableNamer] struct {
 	allFields  []fieldOffsetType
 
 	primaryKeyDefined bool
+	createdAtDefined  bool
+	updatedAtDefined  bool
 }
 
 // ========================================
@@ -22,6 +26,11 @@ func (s *Schema[T]) getTableTypeName() string {
 	return s.def.tableType.String()
 }
 
+// typeName is similar to getTableTypeName, but can also be used after the schema definition
+func (s *Schema[T]) typeName() string {
+	return reflect.TypeFor[T]().String()
+}
+
 type schemaDefinition[T any] struct {
 	table          *T
 	tableAddr      unsafe.Pointer
@@ -67,11 +76,27 @@ func (t fieldSpecType) isVisible() bool {
 
 type fieldInfo struct {
 	dbName       string
+	fieldType    reflect.Type
+	fieldIndex   []int // index sequence for reflect.Value.FieldByIndex
 	specType     fieldSpecType
 	isAutoInc    bool
 	isPrimaryKey bool
+
+	// isNullable is true when the field type is null.Null[F] or a pointer
+	isNullable bool
+
+	autoTime autoTimeType
 }
 
+// autoTimeType is the kind of the timestamp fields set by Executor
+type autoTimeType int
+
+const (
+	autoTimeNone autoTimeType = iota
+	autoTimeCreatedAt
+	autoTimeUpdatedAt
+)
+
 func RegisterSchema[T TableNamer](
 	definitionFn func(s *Schema[T], table *T),
 ) *Schema[T] {
@@ -80,19 +105,25 @@ func RegisterSchema[T TableNamer](
 		fieldInfos: map[fieldOffsetType]fieldInfo{},
 	}
 
-	for index := range s.def.tableType.NumField() {
-		field := s.def.tableType.Field(index)
-		offset := fieldOffsetType(field.Offset)
-		s.allFields = append(s.allFields, offset)
-		s.def.fieldOffsetMap[offset] = field
+	for _, f := range collectStructFields(s.def.tableType) {
+		field := f.field
+		if field.Anonymous && field.Type.Kind() == reflect.Pointer && len(field.Tag.Get(DBTag)) == 0 {
+			panicFormat("embedded pointer field '%s' in type '%s' is not supported", field.Name, s.getTableTypeName())
+		}
+
+		s.allFields = append(s.allFields, f.offset)
+		s.def.fieldOffsetMap[f.offset] = field
 
 		dbName := field.Tag.Get(DBTag)
 		if len(dbName) == 0 {
 			panicFormat("missing struct tag of field '%s' in type '%s'", field.Name, s.getTableTypeName())
 		}
 
-		s.fieldInfos[offset] = fieldInfo{
-			dbName: dbName,
+		s.fieldInfos[f.offset] = fieldInfo{
+			dbName:     dbName,
+			fieldType:  field.Type,
+			fieldIndex: f.index,
+			isNullable: isNullableType(field.Type),
 		}
 	}
 
@@ -115,6 +146,42 @@ func RegisterSchema[T TableNamer](
 	return s
 }
 
+// structField is a field of the table struct, the fields of embedded structs are flattened
+type structField struct {
+	field  reflect.StructField
+	offset fieldOffsetType // from the beginning of the table struct
+	index  []int
+}
+
+// collectStructFields returns the fields in declaration order.
+// An embedded struct without the db tag is replaced by its fields, like sqlx does when scanning
+func collectStructFields(tableType reflect.Type) []structField {
+	var result []structField
+
+	var walk func(typ reflect.Type, baseOffset fieldOffsetType, baseIndex []int)
+	walk = func(typ reflect.Type, baseOffset fieldOffsetType, baseIndex []int) {
+		for i := range typ.NumField() {
+			field := typ.Field(i)
+			offset := baseOffset + fieldOffsetType(field.Offset)
+			index := append(slices.Clone(baseIndex), i)
+
+			if field.Anonymous && field.Type.Kind() == reflect.Struct && len(field.Tag.Get(DBTag)) == 0 {
+				walk(field.Type, offset, index)
+				continue
+			}
+
+			result = append(result, structField{
+				field:  field,
+				offset: offset,
+				index:  index,
+			})
+		}
+	}
+	walk(tableType, 0, nil)
+
+	return result
+}
+
 // ==========================================
 // Schema Definition Functions
 // ==========================================
@@ -200,6 +267,35 @@ func SchemaIgnore[T TableNamer, F any](s *Schema[T], field *F) {
 	})
 }
 
+// SchemaCreatedAt is similar to SchemaConst, but Executor.Insert sets the field to the current time
+func SchemaCreatedAt[T TableNamer](s *Schema[T], field *time.Time) {
+	offset := s.getOffsetOfField(unsafe.Pointer(field))
+	if s.createdAtDefined {
+		panicFormat("created at field of type '%s' has already been specified", s.getTableTypeName())
+	}
+	s.createdAtDefined = true
+
+	s.updateFieldInfo(offset, func(info *fieldInfo) {
+		info.specType = fieldSpecConst
+		info.autoTime = autoTimeCreatedAt
+	})
+}
+
+// SchemaUpdatedAt is similar to SchemaEditable,
+// but Executor.Insert and Executor.Update set the field to the current time
+func SchemaUpdatedAt[T TableNamer](s *Schema[T], field *time.Time) {
+	offset := s.getOffsetOfField(unsafe.Pointer(field))
+	if s.updatedAtDefined {
+		panicFormat("updated at field of type '%s' has already been specified", s.getTableTypeName())
+	}
+	s.updatedAtDefined = true
+
+	s.updateFieldInfo(offset, func(info *fieldInfo) {
+		info.specType = fieldSpecEditable
+		info.autoTime = autoTimeUpdatedAt
+	})
+}
+
 // ==========================================
 // Schema Validation Functions
 // ==========================================
@@ -0,0 +1,123 @@
+package dbc
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// CreateTableSQL returns the CREATE TABLE statement of the schema, as a starting point for a migration file.
+// Column types are derived from the Go field types, null.Null[F] and pointer fields are nullable.
+// Ignored fields are not included because they are never read or written by the Executor
+func (s *Schema[T]) CreateTableSQL(dialect DatabaseDialect) (string, error) {
+	switch dialect {
+	case DialectMysql, DialectPostgres, DialectSqlite:
+	default:
+		return "", fmt.Errorf("unsupported database dialect: %v", dialect)
+	}
+
+	var empty T
+	quote := func(name string) string {
+		return quoteIdentWithDialect(dialect, name)
+	}
+
+	var columnDefs []string
+	var primaryKeys []string
+	inlinePrimaryKey := false
+
+	for _, offset := range s.allFields {
+		info := s.fieldInfos[offset]
+		if !info.specType.isVisible() {
+			continue
+		}
+		if info.isPrimaryKey {
+			primaryKeys = append(primaryKeys, quote(info.dbName))
+		}
+
+		colType, err := columnTypeOf(dialect, info.fieldType)
+		if err != nil {
+			return "", fmt.Errorf("column '%s' of type '%s': %w", info.dbName, s.typeName(), err)
+		}
+
+		def := quote(info.dbName) + " "
+		switch {
+		case info.isAutoInc && dialect == DialectMysql:
+			def += colType + " NOT NULL AUTO_INCREMENT"
+		case info.isAutoInc && dialect == DialectPostgres:
+			def += "BIGSERIAL NOT NULL"
+		case info.isAutoInc && dialect == DialectSqlite:
+			// an alias of the rowid, is assigned automatically
+			def += "INTEGER PRIMARY KEY"
+			inlinePrimaryKey = true
+		case info.isNullable:
+			def += colType + " NULL"
+		default:
+			def += colType + " NOT NULL"
+		}
+		columnDefs = append(columnDefs, def)
+	}
+
+	if !inlinePrimaryKey {
+		columnDefs = append(columnDefs, "PRIMARY KEY ("+strings.Join(primaryKeys, ", ")+")")
+	}
+
+	var buf strings.Builder
+	buf.WriteString("CREATE TABLE ")
+	buf.WriteString(quote(empty.TableName()))
+	buf.WriteString(" (\n")
+	for index, def := range columnDefs {
+		buf.WriteString("    ")
+		buf.WriteString(def)
+		if index < len(columnDefs)-1 {
+			buf.WriteString(",")
+		}
+		buf.WriteString("\n")
+	}
+	buf.WriteString(");\n")
+	return buf.String(), nil
+}
+
+var timeType = reflect.TypeFor[time.Time]()
+
+func columnTypeOf(dialect DatabaseDialect, typ reflect.Type) (string, error) {
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	} else if isNullableType(typ) {
+		dataField, _ := typ.FieldByName("Data")
+		typ = dataField.Type
+	}
+
+	pick := func(mysqlType, postgresType, sqliteType string) (string, error) {
+		switch dialect {
+		case DialectMysql:
+			return mysqlType, nil
+		case DialectPostgres:
+			return postgresType, nil
+		default:
+			return sqliteType, nil
+		}
+	}
+
+	if typ == timeType {
+		return pick("DATETIME(6)", "TIMESTAMPTZ", "DATETIME")
+	}
+
+	switch typ.Kind() {
+	case reflect.Int64, reflect.Int, reflect.Uint32:
+		return pick("BIGINT", "BIGINT", "INTEGER")
+	case reflect.Int32, reflect.Int16, reflect.Int8, reflect.Uint16, reflect.Uint8:
+		return pick("INT", "INTEGER", "INTEGER")
+	case reflect.Float64, reflect.Float32:
+		return pick("DOUBLE", "DOUBLE PRECISION", "REAL")
+	case reflect.Bool:
+		return pick("BOOLEAN", "BOOLEAN", "INTEGER")
+	case reflect.String:
+		return pick("VARCHAR(255)", "TEXT", "TEXT")
+	case reflect.Slice:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			return pick("BLOB", "BYTEA", "BLOB")
+		}
+	}
+	return "", fmt.Errorf("unsupported field type '%s'", typ.String())
+}
@@ -0,0 +1,135 @@
+package dbc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type tagTable struct {
+	ID   int64    `db:"id"`
+	Tags []string `db:"tags"`
+}
+
+func (tagTable) TableName() string {
+	return "tag_table"
+}
+
+func newTableTest03Schema() *Schema[tableTest03] {
+	return RegisterSchema(func(s *Schema[tableTest03], table *tableTest03) {
+		SchemaIDAutoInc(s, &table.ID)
+		SchemaConst(s, &table.RoleID)
+		SchemaEditable(s, &table.Username)
+		SchemaEditable(s, &table.Age)
+		SchemaConst(s, &table.CreatedAt)
+		SchemaIgnore(s, &table.UpdatedAt)
+	})
+}
+
+func TestSchema_CreateTableSQL(t *testing.T) {
+	t.Run("auto inc mysql", func(t *testing.T) {
+		query, err := newTableTest03Schema().CreateTableSQL(DialectMysql)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, "CREATE TABLE `table_test03` (\n"+
+			"    `id` BIGINT NOT NULL AUTO_INCREMENT,\n"+
+			"    `role_id` BIGINT NOT NULL,\n"+
+			"    `username` VARCHAR(255) NOT NULL,\n"+
+			"    `age` BIGINT NOT NULL,\n"+
+			"    `created_at` DATETIME(6) NOT NULL,\n"+
+			"    PRIMARY KEY (`id`)\n"+
+			");\n", query)
+	})
+
+	t.Run("auto inc postgres", func(t *testing.T) {
+		query, err := newTableTest03Schema().CreateTableSQL(DialectPostgres)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, `CREATE TABLE "table_test03" (
+    "id" BIGSERIAL NOT NULL,
+    "role_id" BIGINT NOT NULL,
+    "username" TEXT NOT NULL,
+    "age" BIGINT NOT NULL,
+    "created_at" TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY ("id")
+);
+`, query)
+	})
+
+	t.Run("auto inc sqlite", func(t *testing.T) {
+		query, err := newTableTest03Schema().CreateTableSQL(DialectSqlite)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, `CREATE TABLE table_test03 (
+    id INTEGER PRIMARY KEY,
+    role_id INTEGER NOT NULL,
+    username TEXT NOT NULL,
+    age INTEGER NOT NULL,
+    created_at DATETIME NOT NULL
+);
+`, query)
+	})
+
+	t.Run("composite primary key", func(t *testing.T) {
+		schema := RegisterSchema(func(s *Schema[tableTest04], table *tableTest04) {
+			SchemaCompositePrimaryKey(s, &table.RoleID)
+			SchemaCompositePrimaryKey(s, &table.Username)
+			SchemaEditable(s, &table.Age)
+			SchemaEditable(s, &table.Desc)
+			SchemaIgnore(s, &table.CreatedAt)
+		})
+
+		query, err := schema.CreateTableSQL(DialectPostgres)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, `CREATE TABLE "table_test04" (
+    "role_id" BIGINT NOT NULL,
+    "username" TEXT NOT NULL,
+    "age" BIGINT NOT NULL,
+    "desc" TEXT NOT NULL,
+    PRIMARY KEY ("role_id", "username")
+);
+`, query)
+	})
+
+	t.Run("nullable and other types", func(t *testing.T) {
+		query, err := newDriftUserSchema().CreateTableSQL(DialectMysql)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, "CREATE TABLE `drift_user` (\n"+
+			"    `id` BIGINT NOT NULL AUTO_INCREMENT,\n"+
+			"    `username` VARCHAR(255) NOT NULL,\n"+
+			"    `age` BIGINT NULL,\n"+
+			"    `desc` VARCHAR(255) NOT NULL,\n"+
+			"    `email` VARCHAR(255) NULL,\n"+
+			"    `score` BOOLEAN NULL,\n"+
+			"    PRIMARY KEY (`id`)\n"+
+			");\n", query)
+	})
+
+	t.Run("unsupported field type", func(t *testing.T) {
+		schema := RegisterSchema(func(s *Schema[tagTable], table *tagTable) {
+			SchemaIDInt64(s, &table.ID)
+			SchemaEditable(s, &table.Tags)
+		})
+
+		_, err := schema.CreateTableSQL(DialectMysql)
+		assert.Equal(t, "column 'tags' of type 'dbc.tagTable': unsupported field type '[]string'", err.Error())
+	})
+
+	t.Run("unsupported dialect", func(t *testing.T) {
+		_, err := newTableTest03Schema().CreateTableSQL(DatabaseDialect(0))
+		assert.Equal(t, errors.New("unsupported database dialect: 0"), err)
+	})
+}
+
+func TestSchema_CreateTableSQL__Verify_SQLite(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	schema := newDriftUserSchema()
+
+	query, err := schema.CreateTableSQL(DialectSqlite)
+	assert.Equal(t, nil, err)
+	execTestQuery(t, db, query)
+
+	result, err := VerifySchema(ctx, db, DialectSqlite, schema)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []SchemaMismatch(nil), result)
+}
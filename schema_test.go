@@ -137,3 +137,72 @@ func TestRegisterSchema_Normal__Use_Definition_Function_Outside(t *testing.T) {
 		SchemaEditable(s, new(int))
 	})
 }
+
+func TestRegisterSchema_Embedded_Struct(t *testing.T) {
+	newTestSchema(t)
+	s := RegisterSchema(func(s *Schema[authUserEmbedded], table *authUserEmbedded) {
+		SchemaIDAutoInc(s, &table.ID)
+		SchemaEditable(s, &table.Username)
+		SchemaConst(s, &table.CreatedAt)
+	})
+
+	cols := s.GetColumnNames(func(g *ColumnGetter[authUserEmbedded], table *authUserEmbedded) {
+		ReturnColumn(g, &table.CreatedAt)
+		ReturnColumn(g, &table.Username)
+		ReturnColumn(g, &table.ID)
+	})
+	assert.Equal(t, []string{"created_at", "username", "id"}, cols)
+
+	var indexes [][]int
+	for _, offset := range s.allFields {
+		indexes = append(indexes, s.fieldInfos[offset].fieldIndex)
+	}
+	assert.Equal(t, [][]int{{0}, {1, 0}, {1, 1, 0}}, indexes)
+}
+
+func TestRegisterSchema_Embedded_Struct__Missing_Col_Spec(t *testing.T) {
+	newTestSchema(t)
+	assert.PanicsWithValue(t, "missing column spec of field 'CreatedAt' in type 'dbc.authUserEmbedded'", func() {
+		RegisterSchema(func(s *Schema[authUserEmbedded], table *authUserEmbedded) {
+			SchemaIDAutoInc(s, &table.ID)
+			SchemaEditable(s, &table.Username)
+		})
+	})
+}
+
+func TestRegisterSchema_Embedded_Pointer(t *testing.T) {
+	newTestSchema(t)
+	assert.PanicsWithValue(t,
+		"embedded pointer field 'testTimestamps' in type 'dbc.tableTest06' is not supported",
+		func() {
+			RegisterSchema(func(s *Schema[tableTest06], table *tableTest06) {
+				SchemaIDInt64(s, &table.ID)
+			})
+		},
+	)
+}
+
+func TestRegisterSchema_Timestamps__Duplicated(t *testing.T) {
+	newTestSchema(t)
+	assert.PanicsWithValue(t, "created at field of type 'dbc.tableTest03' has already been specified", func() {
+		RegisterSchema(func(s *Schema[tableTest03], table *tableTest03) {
+			SchemaIDInt64(s, &table.ID)
+			SchemaConst(s, &table.RoleID)
+			SchemaEditable(s, &table.Username)
+			SchemaEditable(s, &table.Age)
+			SchemaCreatedAt(s, &table.CreatedAt)
+			SchemaCreatedAt(s, &table.UpdatedAt)
+		})
+	})
+
+	assert.PanicsWithValue(t, "updated at field of type 'dbc.tableTest03' has already been specified", func() {
+		RegisterSchema(func(s *Schema[tableTest03], table *tableTest03) {
+			SchemaIDInt64(s, &table.ID)
+			SchemaConst(s, &table.RoleID)
+			SchemaEditable(s, &table.Username)
+			SchemaEditable(s, &table.Age)
+			SchemaUpdatedAt(s, &table.CreatedAt)
+			SchemaUpdatedAt(s, &table.UpdatedAt)
+		})
+	})
+}
@@ -0,0 +1,165 @@
+package dbc
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/QuangTung97/dbc/null"
+)
+
+type SchemaMismatchKind int
+
+const (
+	// SchemaMismatchMissingTable means the table of the schema does not exist
+	SchemaMismatchMissingTable SchemaMismatchKind = iota + 1
+
+	// SchemaMismatchMissingColumn means a non-ignored field has no column in the table
+	SchemaMismatchMissingColumn
+
+	// SchemaMismatchExtraColumn means a NOT NULL column without default is not written by the schema,
+	// so every Insert will fail
+	SchemaMismatchExtraColumn
+
+	// SchemaMismatchNullability means the column is nullable but the field is not null.Null[F], or vice versa
+	SchemaMismatchNullability
+
+	// SchemaMismatchPrimaryKey means the primary key of the table differs from the one of the schema
+	SchemaMismatchPrimaryKey
+)
+
+// SchemaMismatch is a difference between a registered Schema and the live table
+type SchemaMismatch struct {
+	Kind    SchemaMismatchKind
+	Table   string
+	Column  string // empty for table level mismatches
+	Message string
+}
+
+func (m SchemaMismatch) String() string {
+	return m.Message
+}
+
+// VerifySchema compares the schema with the table in the database,
+// using information_schema on MySQL and Postgres and PRAGMA table_info on SQLite.
+// An empty result means no drift was found
+func VerifySchema[T TableNamer](
+	ctx context.Context, db Readonly, dialect DatabaseDialect, schema *Schema[T],
+) ([]SchemaMismatch, error) {
+	var empty T
+	tableName := empty.TableName()
+
+	columns, err := GetTableColumns(ctx, db, dialect, tableName)
+	if err != nil {
+		return nil, err
+	}
+	return compareSchemaColumns(schema, tableName, columns), nil
+}
+
+func compareSchemaColumns[T TableNamer](
+	schema *Schema[T], tableName string, columns []TableColumn,
+) []SchemaMismatch {
+	if len(columns) == 0 {
+		return []SchemaMismatch{{
+			Kind:    SchemaMismatchMissingTable,
+			Table:   tableName,
+			Message: fmt.Sprintf("table '%s' not found", tableName),
+		}}
+	}
+
+	typeName := schema.typeName()
+
+	columnMap := map[string]TableColumn{}
+	var dbPrimaryKeys []string
+	for _, col := range columns {
+		columnMap[col.Name] = col
+		if col.PrimaryKey {
+			dbPrimaryKeys = append(dbPrimaryKeys, col.Name)
+		}
+	}
+
+	var result []SchemaMismatch
+	newMismatch := func(kind SchemaMismatchKind, column string, format string, args ...any) {
+		result = append(result, SchemaMismatch{
+			Kind:    kind,
+			Table:   tableName,
+			Column:  column,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	visibleColumns := map[string]struct{}{}
+	var schemaPrimaryKeys []string
+
+	for _, offset := range schema.allFields {
+		info := schema.fieldInfos[offset]
+		if info.isPrimaryKey {
+			schemaPrimaryKeys = append(schemaPrimaryKeys, info.dbName)
+		}
+		if !info.specType.isVisible() {
+			continue
+		}
+		visibleColumns[info.dbName] = struct{}{}
+
+		col, ok := columnMap[info.dbName]
+		if !ok {
+			newMismatch(
+				SchemaMismatchMissingColumn, info.dbName,
+				"column '%s' of type '%s' not found in table '%s'", info.dbName, typeName, tableName,
+			)
+			continue
+		}
+
+		if col.Nullable && !info.isNullable {
+			newMismatch(
+				SchemaMismatchNullability, info.dbName,
+				"column '%s' in table '%s' is nullable but its field in type '%s' is not null.Null",
+				info.dbName, tableName, typeName,
+			)
+		} else if !col.Nullable && info.isNullable {
+			newMismatch(
+				SchemaMismatchNullability, info.dbName,
+				"column '%s' in table '%s' is NOT NULL but its field in type '%s' is nullable",
+				info.dbName, tableName, typeName,
+			)
+		}
+	}
+
+	for _, col := range columns {
+		if _, ok := visibleColumns[col.Name]; ok {
+			continue
+		}
+		// unmapped primary key columns are reported as a primary key mismatch
+		if col.Nullable || col.HasDefault || col.PrimaryKey {
+			continue
+		}
+		newMismatch(
+			SchemaMismatchExtraColumn, col.Name,
+			"column '%s' in table '%s' is NOT NULL without default but not written by type '%s'",
+			col.Name, tableName, typeName,
+		)
+	}
+
+	slices.Sort(dbPrimaryKeys)
+	slices.Sort(schemaPrimaryKeys)
+	if !slices.Equal(dbPrimaryKeys, schemaPrimaryKeys) {
+		newMismatch(
+			SchemaMismatchPrimaryKey, "",
+			"primary key of table '%s' is (%s) but type '%s' defines (%s)",
+			tableName, strings.Join(dbPrimaryKeys, ", "), typeName, strings.Join(schemaPrimaryKeys, ", "),
+		)
+	}
+
+	return result
+}
+
+var nullTypePkgPath = reflect.TypeFor[null.Null[int]]().PkgPath()
+
+func isNullableType(typ reflect.Type) bool {
+	if typ.Kind() == reflect.Pointer {
+		return true
+	}
+	return typ.PkgPath() == nullTypePkgPath && strings.HasPrefix(typ.Name(), "Null[")
+}
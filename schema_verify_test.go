@@ -0,0 +1,171 @@
+package dbc
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/QuangTung97/dbc/null"
+)
+
+type driftUser struct {
+	ID        int64           `db:"id"`
+	Username  string          `db:"username"`
+	Age       null.Null[int]  `db:"age"`
+	Desc      string          `db:"desc"`
+	Email     *string         `db:"email"`
+	CreatedAt time.Time       `db:"created_at"`
+	Score     null.Null[bool] `db:"score"`
+}
+
+func (driftUser) TableName() string {
+	return "drift_user"
+}
+
+func newDriftUserSchema() *Schema[driftUser] {
+	return RegisterSchema(func(s *Schema[driftUser], table *driftUser) {
+		SchemaIDAutoInc(s, &table.ID)
+		SchemaEditable(s, &table.Username)
+		SchemaEditable(s, &table.Age)
+		SchemaEditable(s, &table.Desc)
+		SchemaEditable(s, &table.Email)
+		SchemaIgnore(s, &table.CreatedAt)
+		SchemaEditable(s, &table.Score)
+	})
+}
+
+func execTestQuery(t *testing.T, db *sqlx.DB, query string) {
+	_, err := db.Exec(query)
+	assert.Equal(t, nil, err)
+}
+
+func TestVerifySchema(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("normal", func(t *testing.T) {
+		db := newTestDB(t)
+
+		result, err := VerifySchema(ctx, db, DialectSqlite, newAuthUserSchema())
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []SchemaMismatch(nil), result)
+	})
+
+	t.Run("missing table", func(t *testing.T) {
+		db := newTestDB(t)
+
+		result, err := VerifySchema(ctx, db, DialectSqlite, newDriftUserSchema())
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []SchemaMismatch{
+			{
+				Kind:    SchemaMismatchMissingTable,
+				Table:   "drift_user",
+				Message: "table 'drift_user' not found",
+			},
+		}, result)
+	})
+
+	t.Run("drift", func(t *testing.T) {
+		db := newTestDB(t)
+		execTestQuery(t, db, `
+CREATE TABLE drift_user (
+    id INTEGER PRIMARY KEY,
+    username TEXT,
+    age INTEGER NOT NULL,
+    email TEXT,
+    score INTEGER,
+    phone TEXT NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    created_at INTEGER NOT NULL
+)`)
+
+		result, err := VerifySchema(ctx, db, DialectSqlite, newDriftUserSchema())
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []SchemaMismatch{
+			{
+				Kind:   SchemaMismatchNullability,
+				Table:  "drift_user",
+				Column: "username",
+				Message: "column 'username' in table 'drift_user' is nullable " +
+					"but its field in type 'dbc.driftUser' is not null.Null",
+			},
+			{
+				Kind:   SchemaMismatchNullability,
+				Table:  "drift_user",
+				Column: "age",
+				Message: "column 'age' in table 'drift_user' is NOT NULL " +
+					"but its field in type 'dbc.driftUser' is nullable",
+			},
+			{
+				Kind:    SchemaMismatchMissingColumn,
+				Table:   "drift_user",
+				Column:  "desc",
+				Message: "column 'desc' of type 'dbc.driftUser' not found in table 'drift_user'",
+			},
+			{
+				Kind:   SchemaMismatchExtraColumn,
+				Table:  "drift_user",
+				Column: "phone",
+				Message: "column 'phone' in table 'drift_user' is NOT NULL without default " +
+					"but not written by type 'dbc.driftUser'",
+			},
+			{
+				Kind:   SchemaMismatchExtraColumn,
+				Table:  "drift_user",
+				Column: "created_at",
+				Message: "column 'created_at' in table 'drift_user' is NOT NULL without default " +
+					"but not written by type 'dbc.driftUser'",
+			},
+		}, result)
+	})
+
+	t.Run("primary key", func(t *testing.T) {
+		db := newTestDB(t)
+		execTestQuery(t, db, `
+CREATE TABLE table_test04 (
+    role_id INTEGER NOT NULL PRIMARY KEY,
+    username TEXT NOT NULL,
+    age INTEGER NOT NULL,
+    desc TEXT NOT NULL,
+    created_at INTEGER
+)`)
+
+		schema := RegisterSchema(func(s *Schema[tableTest04], table *tableTest04) {
+			SchemaCompositePrimaryKey(s, &table.RoleID)
+			SchemaCompositePrimaryKey(s, &table.Username)
+			SchemaEditable(s, &table.Age)
+			SchemaEditable(s, &table.Desc)
+			SchemaIgnore(s, &table.CreatedAt)
+		})
+
+		result, err := VerifySchema(ctx, db, DialectSqlite, schema)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []SchemaMismatch{
+			{
+				Kind:    SchemaMismatchPrimaryKey,
+				Table:   "table_test04",
+				Message: "primary key of table 'table_test04' is (role_id) but type 'dbc.tableTest04' defines (role_id, username)",
+			},
+		}, result)
+	})
+
+	t.Run("unsupported dialect", func(t *testing.T) {
+		db := newTestDB(t)
+
+		result, err := VerifySchema(ctx, db, DatabaseDialect(0), newAuthUserSchema())
+		assert.Equal(t, errors.New("unsupported database dialect: 0"), err)
+		assert.Equal(t, []SchemaMismatch(nil), result)
+	})
+}
+
+func TestIsNullableType(t *testing.T) {
+	assert.Equal(t, true, isNullableType(reflect.TypeFor[null.Null[int64]]()))
+	assert.Equal(t, true, isNullableType(reflect.TypeFor[null.Null[testRoleID]]()))
+	assert.Equal(t, true, isNullableType(reflect.TypeFor[*string]()))
+	assert.Equal(t, false, isNullableType(reflect.TypeFor[int64]()))
+	assert.Equal(t, false, isNullableType(reflect.TypeFor[time.Time]()))
+}
@@ -0,0 +1,94 @@
+package dbc
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+type SlogQueryHookConfig struct {
+	// Logger defaults to slog.Default()
+	Logger *slog.Logger
+
+	// Level is the log level of normal queries, defaults to slog.LevelDebug
+	Level slog.Leveler
+
+	// SlowThreshold is the duration that a query is considered slow and logged with slog.LevelWarn.
+	// Zero means disabled
+	SlowThreshold time.Duration
+
+	// RedactArgs replaces all query arguments with RedactedArg in the log output
+	RedactArgs bool
+}
+
+const RedactedArg = "[REDACTED]"
+
+// SlogQueryHook is an implementation of QueryHook using log/slog.
+// Failed queries are logged with slog.LevelError, sql.ErrNoRows is not considered a failure
+type SlogQueryHook struct {
+	conf SlogQueryHookConfig
+}
+
+var _ QueryHook = &SlogQueryHook{}
+
+func NewSlogQueryHook(conf SlogQueryHookConfig) *SlogQueryHook {
+	if conf.Logger == nil {
+		conf.Logger = slog.Default()
+	}
+	if conf.Level == nil {
+		conf.Level = slog.LevelDebug
+	}
+	return &SlogQueryHook{
+		conf: conf,
+	}
+}
+
+func (h *SlogQueryHook) BeforeQuery(ctx context.Context, _ string, _ []any) context.Context {
+	return ctx
+}
+
+func (h *SlogQueryHook) AfterQuery(
+	ctx context.Context, query string, args []any, duration time.Duration, err error,
+) {
+	level := h.conf.Level.Level()
+	msg := "Execute query"
+
+	if h.conf.SlowThreshold > 0 && duration >= h.conf.SlowThreshold {
+		level = slog.LevelWarn
+		msg = "Slow query"
+	}
+
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		level = slog.LevelError
+		msg = "Query failed"
+	}
+
+	if !h.conf.Logger.Enabled(ctx, level) {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("query", query),
+		slog.Any("args", h.getLogArgs(args)),
+		slog.Duration("duration", duration),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+
+	h.conf.Logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+func (h *SlogQueryHook) getLogArgs(args []any) []any {
+	if !h.conf.RedactArgs {
+		return args
+	}
+
+	result := make([]any, 0, len(args))
+	for range args {
+		result = append(result, RedactedArg)
+	}
+	return result
+}
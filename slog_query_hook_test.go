@@ -0,0 +1,110 @@
+package dbc
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newSlogHookTest(conf SlogQueryHookConfig) (*SlogQueryHook, *bytes.Buffer) {
+	var buf bytes.Buffer
+	conf.Logger = slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+	return NewSlogQueryHook(conf), &buf
+}
+
+func TestSlogQueryHook(t *testing.T) {
+	ctx := context.Background()
+	query := "SELECT * FROM users WHERE id = ?"
+
+	t.Run("normal", func(t *testing.T) {
+		h, buf := newSlogHookTest(SlogQueryHookConfig{})
+
+		h.AfterQuery(ctx, query, []any{21}, 3*time.Millisecond, nil)
+		assert.Equal(
+			t,
+			`level=DEBUG msg="Execute query" query="SELECT * FROM users WHERE id = ?" args=[21] duration=3ms`+"\n",
+			buf.String(),
+		)
+	})
+
+	t.Run("custom level", func(t *testing.T) {
+		h, buf := newSlogHookTest(SlogQueryHookConfig{
+			Level: slog.LevelInfo,
+		})
+
+		h.AfterQuery(ctx, query, []any{21}, 3*time.Millisecond, sql.ErrNoRows)
+		assert.Equal(
+			t,
+			`level=INFO msg="Execute query" query="SELECT * FROM users WHERE id = ?" args=[21] duration=3ms`+
+				` error="sql: no rows in result set"`+"\n",
+			buf.String(),
+		)
+	})
+
+	t.Run("slow query", func(t *testing.T) {
+		h, buf := newSlogHookTest(SlogQueryHookConfig{
+			SlowThreshold: 100 * time.Millisecond,
+		})
+
+		h.AfterQuery(ctx, query, []any{21}, 99*time.Millisecond, nil)
+		h.AfterQuery(ctx, query, []any{22}, 100*time.Millisecond, nil)
+		assert.Equal(
+			t,
+			`level=DEBUG msg="Execute query" query="SELECT * FROM users WHERE id = ?" args=[21] duration=99ms`+"\n"+
+				`level=WARN msg="Slow query" query="SELECT * FROM users WHERE id = ?" args=[22] duration=100ms`+"\n",
+			buf.String(),
+		)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		h, buf := newSlogHookTest(SlogQueryHookConfig{
+			SlowThreshold: 100 * time.Millisecond,
+		})
+
+		h.AfterQuery(ctx, query, []any{21}, 200*time.Millisecond, errors.New("some error"))
+		assert.Equal(
+			t,
+			`level=ERROR msg="Query failed" query="SELECT * FROM users WHERE id = ?" args=[21] duration=200ms`+
+				` error="some error"`+"\n",
+			buf.String(),
+		)
+	})
+
+	t.Run("redact args", func(t *testing.T) {
+		h, buf := newSlogHookTest(SlogQueryHookConfig{
+			RedactArgs: true,
+		})
+
+		h.AfterQuery(ctx, query, []any{21, "secret"}, 3*time.Millisecond, nil)
+		assert.Equal(
+			t,
+			`level=DEBUG msg="Execute query" query="SELECT * FROM users WHERE id = ?"`+
+				` args="[[REDACTED] [REDACTED]]" duration=3ms`+"\n",
+			buf.String(),
+		)
+	})
+
+	t.Run("level not enabled", func(t *testing.T) {
+		var buf bytes.Buffer
+		h := NewSlogQueryHook(SlogQueryHookConfig{
+			Logger: slog.New(slog.NewTextHandler(&buf, nil)),
+		})
+
+		h.AfterQuery(ctx, query, []any{21}, 3*time.Millisecond, nil)
+		assert.Equal(t, "", buf.String())
+	})
+}
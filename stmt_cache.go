@@ -0,0 +1,308 @@
+package dbc
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// WithStatementCache enables a cache of prepared statements for the static queries of Executor,
+// i.e. of GetByID, GetWithLock, Insert, Update, Upsert and Delete, keyed by the query text.
+// At most 'size' statements are kept, the least recently used one is closed first.
+// Inside Provider.Transact the cached statements are bound to the transaction,
+// the ones not found in the cache are prepared on the transaction without being cached.
+// Other queries, e.g. of GetMulti or the ones executed directly on GetTx(ctx), are not prepared
+func WithStatementCache(size int) ProviderOption {
+	return func(opts *providerOptions) {
+		opts.stmtCacheSize = size
+	}
+}
+
+type stmtCache struct {
+	db   *sqlx.DB
+	size int
+
+	mut     sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List // of *stmtCacheEntry, the front is the most recently used
+}
+
+type stmtCacheEntry struct {
+	query string
+	stmt  *sqlx.Stmt
+
+	// refs is the number of users of the statement, it is closed after evicted and refs = 0
+	refs    int
+	evicted bool
+}
+
+func newStmtCache(db *sqlx.DB, size int) *stmtCache {
+	return &stmtCache{
+		db:      db,
+		size:    size,
+		entries: map[string]*list.Element{},
+		lru:     list.New(),
+	}
+}
+
+// acquire returns the cached statement of the query, preparing it if not found.
+// Every call must be followed by a call to release
+func (c *stmtCache) acquire(ctx context.Context, query string) (*stmtCacheEntry, error) {
+	if entry, ok := c.getEntry(query); ok {
+		return entry, nil
+	}
+
+	// prepare outside of the lock, so that slow preparing does not block other queries
+	stmt, err := c.db.PreparexContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if elem, ok := c.entries[query]; ok {
+		// prepared concurrently by another goroutine
+		_ = stmt.Close()
+		c.lru.MoveToFront(elem)
+		entry := elem.Value.(*stmtCacheEntry)
+		entry.refs++
+		return entry, nil
+	}
+
+	entry := &stmtCacheEntry{
+		query: query,
+		stmt:  stmt,
+		refs:  1,
+	}
+	c.entries[query] = c.lru.PushFront(entry)
+
+	for c.lru.Len() > c.size {
+		c.evictLocked(c.lru.Back())
+	}
+	return entry, nil
+}
+
+func (c *stmtCache) getEntry(query string) (*stmtCacheEntry, bool) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	elem, ok := c.entries[query]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	entry := elem.Value.(*stmtCacheEntry)
+	entry.refs++
+	return entry, true
+}
+
+// release removes the statement from the cache if err is a connection error
+func (c *stmtCache) release(entry *stmtCacheEntry, err error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if isConnError(err) {
+		c.invalidateLocked(entry)
+	}
+
+	entry.refs--
+	if entry.evicted && entry.refs == 0 {
+		_ = entry.stmt.Close()
+	}
+}
+
+// invalidate removes the statement from the cache, it is closed after released by all users
+func (c *stmtCache) invalidate(entry *stmtCacheEntry) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.invalidateLocked(entry)
+}
+
+func (c *stmtCache) invalidateLocked(entry *stmtCacheEntry) {
+	if elem, ok := c.entries[entry.query]; ok && elem.Value == entry {
+		c.evictLocked(elem)
+	}
+}
+
+func (c *stmtCache) evictLocked(elem *list.Element) {
+	entry := elem.Value.(*stmtCacheEntry)
+	c.lru.Remove(elem)
+	delete(c.entries, entry.query)
+
+	entry.evicted = true
+	if entry.refs == 0 {
+		_ = entry.stmt.Close()
+	}
+}
+
+func (c *stmtCache) len() int {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return c.lru.Len()
+}
+
+// isConnError returns true if the statement should not be used anymore
+func isConnError(err error) bool {
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone)
+}
+
+// isStaticQuery returns true for the queries precomputed in NewExecutor. The other queries of Executor,
+// e.g. of GetMulti or SelectCond, change with the arguments and would only push the static ones out of the cache
+func isStaticQuery(ctx context.Context) bool {
+	info, ok := ctx.Value(statementInfoKey{}).(statementInfo)
+	return ok && info.static
+}
+
+// cachedTransaction executes the static queries of Executor using the statements of stmtCache
+type cachedTransaction struct {
+	Transaction // *sqlx.DB or *sqlx.Tx, for the other queries
+
+	cache *stmtCache
+	tx    *sqlx.Tx // nil outside of Provider.Transact
+
+	// entries are only used inside a transaction, and are released after commit or rollback
+	mut     sync.Mutex
+	entries map[string]*stmtCacheEntry
+	txStmts map[string]*sqlx.Stmt
+}
+
+var _ Transaction = &cachedTransaction{}
+
+func wrapWithStmtCache(db *sqlx.DB, cache *stmtCache) Transaction {
+	if cache == nil {
+		return db
+	}
+	return &cachedTransaction{
+		Transaction: db,
+		cache:       cache,
+	}
+}
+
+func wrapTxWithStmtCache(tx *sqlx.Tx, cache *stmtCache) (Transaction, func()) {
+	if cache == nil {
+		return tx, func() {}
+	}
+	t := &cachedTransaction{
+		Transaction: tx,
+		cache:       cache,
+		tx:          tx,
+		entries:     map[string]*stmtCacheEntry{},
+		txStmts:     map[string]*sqlx.Stmt{},
+	}
+	return t, t.finish
+}
+
+func (t *cachedTransaction) withStmt(ctx context.Context, query string, fn func(stmt *sqlx.Stmt) error) error {
+	if t.tx == nil {
+		entry, err := t.cache.acquire(ctx, query)
+		if err != nil {
+			return err
+		}
+		err = fn(entry.stmt)
+		t.cache.release(entry, err)
+		return err
+	}
+
+	stmt, err := t.getTxStmt(ctx, query)
+	if err != nil {
+		return err
+	}
+	return fn(stmt)
+}
+
+func (t *cachedTransaction) getTxStmt(ctx context.Context, query string) (*sqlx.Stmt, error) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	if stmt, ok := t.txStmts[query]; ok {
+		return stmt, nil
+	}
+
+	entry, ok := t.cache.getEntry(query)
+	if !ok {
+		// prepare on the connection of the transaction, because preparing on the pool would wait forever
+		// for a free connection when the pool has only one, the usual setup of sqlite
+		stmt, err := t.tx.PreparexContext(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		t.txStmts[query] = stmt
+		return stmt, nil
+	}
+	stmt := t.tx.StmtxContext(ctx, entry.stmt)
+
+	t.entries[query] = entry
+	t.txStmts[query] = stmt
+	return stmt, nil
+}
+
+// finish releases the cached statements after the transaction is finished,
+// the statements bound to or prepared on the transaction are closed by the transaction itself
+func (t *cachedTransaction) finish() {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	for _, entry := range t.entries {
+		t.cache.release(entry, nil)
+	}
+	t.entries = nil
+	t.txStmts = nil
+}
+
+// txError invalidates the cached statement of the query after a connection error inside the transaction.
+// Outside of transactions it is done by stmtCache.release
+func (t *cachedTransaction) txError(query string, err error) {
+	if t.tx == nil || !isConnError(err) {
+		return
+	}
+
+	t.mut.Lock()
+	entry, ok := t.entries[query]
+	t.mut.Unlock()
+
+	if ok {
+		t.cache.invalidate(entry)
+	}
+}
+
+func (t *cachedTransaction) GetContext(ctx context.Context, dest any, query string, args ...any) error {
+	if !isStaticQuery(ctx) {
+		return t.Transaction.GetContext(ctx, dest, query, args...)
+	}
+	err := t.withStmt(ctx, query, func(stmt *sqlx.Stmt) error {
+		return stmt.GetContext(ctx, dest, args...)
+	})
+	t.txError(query, err)
+	return err
+}
+
+func (t *cachedTransaction) SelectContext(ctx context.Context, dest any, query string, args ...any) error {
+	if !isStaticQuery(ctx) {
+		return t.Transaction.SelectContext(ctx, dest, query, args...)
+	}
+	err := t.withStmt(ctx, query, func(stmt *sqlx.Stmt) error {
+		return stmt.SelectContext(ctx, dest, args...)
+	})
+	t.txError(query, err)
+	return err
+}
+
+func (t *cachedTransaction) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if !isStaticQuery(ctx) {
+		return t.Transaction.ExecContext(ctx, query, args...)
+	}
+	var result sql.Result
+	err := t.withStmt(ctx, query, func(stmt *sqlx.Stmt) error {
+		var err error
+		result, err = stmt.ExecContext(ctx, args...)
+		return err
+	})
+	t.txError(query, err)
+	return result, err
+}
@@ -0,0 +1,247 @@
+package dbc
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type stmtCacheTest struct {
+	provider *providerImpl
+	exec     *Executor[authUser]
+}
+
+func newStmtCacheTest(t *testing.T, size int) *stmtCacheTest {
+	db := newTestDB(t)
+	provider := NewProvider(db, WithStatementCache(size)).(*providerImpl)
+
+	exec, err := NewExecutor(DialectSqlite, newAuthUserSchema(), WithProvider(provider))
+	assert.Equal(t, nil, err)
+
+	return &stmtCacheTest{
+		provider: provider,
+		exec:     exec,
+	}
+}
+
+// cachedQueries returns the queries in the cache, from the most recently used
+func (c *stmtCacheTest) cachedQueries() []string {
+	cache := c.provider.stmtCache
+	cache.mut.Lock()
+	defer cache.mut.Unlock()
+
+	var result []string
+	for elem := cache.lru.Front(); elem != nil; elem = elem.Next() {
+		result = append(result, elem.Value.(*stmtCacheEntry).query)
+	}
+	return result
+}
+
+func TestStatementCache__Autocommit(t *testing.T) {
+	c := newStmtCacheTest(t, 2)
+	ctx := c.provider.Autocommit(context.Background())
+
+	user01 := authUser{Username: "user01", CreatedAt: 2001}
+	err := c.exec.Insert(ctx, &user01)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(1), user01.ID)
+
+	user02 := authUser{Username: "user02", CreatedAt: 2002}
+	err = c.exec.Insert(ctx, &user02)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(2), user02.ID)
+
+	result, err := c.exec.GetByID(ctx, authUser{ID: user02.ID})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, user02, result.Data)
+
+	assert.Equal(t, []string{
+		c.exec.plan.getByIDQuery,
+		c.exec.plan.insertQuery,
+	}, c.cachedQueries())
+
+	// not found is not an error of the statement
+	result, err = c.exec.GetByID(ctx, authUser{ID: 3})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, false, result.Valid)
+
+	// evict the least recently used
+	err = c.exec.Update(ctx, authUser{ID: user01.ID, Username: "user01-new"})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{
+		c.exec.plan.updateQuery,
+		c.exec.plan.getByIDQuery,
+	}, c.cachedQueries())
+
+	// prepared again after evicted
+	user03 := authUser{Username: "user03", CreatedAt: 2003}
+	err = c.exec.Insert(ctx, &user03)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(3), user03.ID)
+
+	cached := c.cachedQueries()
+
+	// the queries changing with the arguments are not cached
+	users, err := c.exec.GetMulti(ctx, []authUser{{ID: 1}, {ID: 2}, {ID: 3}})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []authUser{
+		{ID: 1, Username: "user01-new", CreatedAt: 2001},
+		user02,
+		user03,
+	}, users)
+
+	users, err = c.exec.SelectCond(ctx, func(b *CondBuilder[authUser], table *authUser) {
+		CondEqual(b, &table.Username, "user02")
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []authUser{user02}, users)
+
+	err = c.exec.DeleteCond(ctx, func(b *CondBuilder[authUser], table *authUser) {
+		CondEqual(b, &table.Username, "user03")
+	})
+	assert.Equal(t, nil, err)
+
+	assert.Equal(t, cached, c.cachedQueries())
+}
+
+func TestStatementCache__Transaction(t *testing.T) {
+	c := newStmtCacheTest(t, 10)
+
+	user01 := authUser{Username: "user01", CreatedAt: 2001}
+	user02 := authUser{Username: "user02", CreatedAt: 2002}
+
+	// cached outside of the transaction
+	result, err := c.exec.GetByID(c.provider.Readonly(context.Background()), authUser{ID: 1})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, false, result.Valid)
+
+	err = c.provider.Transact(context.Background(), func(ctx context.Context) error {
+		if err := c.exec.Insert(ctx, &user01); err != nil {
+			return err
+		}
+		if err := c.exec.Insert(ctx, &user02); err != nil {
+			return err
+		}
+
+		result, err := c.exec.GetByID(ctx, authUser{ID: user02.ID})
+		assert.Equal(t, nil, err)
+		assert.Equal(t, user02, result.Data)
+
+		// statements are held by the transaction, the ones not in the cache are prepared on the transaction
+		cachedTx := GetTxOf(ctx, c.provider).(*cachedTransaction)
+		assert.Equal(t, 2, len(cachedTx.txStmts))
+		assert.Equal(t, 1, len(cachedTx.entries))
+		assert.Equal(t, 1, cachedTx.entries[c.exec.plan.getByIDQuery].refs)
+
+		// queries not executed by Executor are not cached
+		var count int
+		err = GetTx(ctx).GetContext(ctx, &count, `SELECT COUNT(*) FROM auth_user`)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, 2, count)
+		return nil
+	})
+	assert.Equal(t, nil, err)
+
+	assert.Equal(t, []string{c.exec.plan.getByIDQuery}, c.cachedQueries())
+
+	// released after commit
+	cache := c.provider.stmtCache
+	for elem := cache.lru.Front(); elem != nil; elem = elem.Next() {
+		assert.Equal(t, 0, elem.Value.(*stmtCacheEntry).refs)
+	}
+
+	// rollback
+	err = c.provider.Transact(context.Background(), func(ctx context.Context) error {
+		if err := c.exec.Delete(ctx, user01); err != nil {
+			return err
+		}
+		return errors.New("rollback")
+	})
+	assert.Equal(t, errors.New("rollback"), err)
+
+	ctx := c.provider.Readonly(context.Background())
+	result, err = c.exec.GetByID(ctx, authUser{ID: user01.ID})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, user01, result.Data)
+	assert.Equal(t, 1, c.provider.stmtCache.len())
+}
+
+func TestStatementCache__Transaction__Single_Connection(t *testing.T) {
+	c := newStmtCacheTest(t, 10)
+	c.provider.db.SetMaxOpenConns(1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	user01 := authUser{Username: "user01", CreatedAt: 2001}
+
+	// cached, then bound to the transaction
+	result, err := c.exec.GetByID(c.provider.Readonly(ctx), authUser{ID: 1})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, false, result.Valid)
+
+	err = c.provider.Transact(ctx, func(ctx context.Context) error {
+		if err := c.exec.Insert(ctx, &user01); err != nil {
+			return err
+		}
+		result, err := c.exec.GetByID(ctx, authUser{ID: user01.ID})
+		if err != nil {
+			return err
+		}
+		assert.Equal(t, user01, result.Data)
+		return nil
+	})
+	assert.Equal(t, nil, err)
+
+	// the cached statement can be used after the transaction
+	result, err = c.exec.GetByID(c.provider.Readonly(ctx), authUser{ID: user01.ID})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, user01, result.Data)
+}
+
+func TestStatementCache__Release(t *testing.T) {
+	c := newStmtCacheTest(t, 1)
+	cache := c.provider.stmtCache
+	ctx := context.Background()
+
+	query := c.exec.plan.getByIDQuery
+
+	t.Run("connection error", func(t *testing.T) {
+		entry, err := cache.acquire(ctx, query)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, 1, cache.len())
+
+		cache.release(entry, driver.ErrBadConn)
+		assert.Equal(t, 0, cache.len())
+		assert.Equal(t, true, entry.evicted)
+
+		var user authUser
+		err = entry.stmt.GetContext(ctx, &user, 1)
+		assert.Equal(t, errors.New("sql: statement is closed"), err)
+	})
+
+	t.Run("evicted while in use", func(t *testing.T) {
+		entry, err := cache.acquire(ctx, query)
+		assert.Equal(t, nil, err)
+
+		other, err := cache.acquire(ctx, c.exec.plan.deleteQuery)
+		assert.Equal(t, nil, err)
+		cache.release(other, nil)
+
+		// still usable until released
+		assert.Equal(t, true, entry.evicted)
+		var user authUser
+		err = entry.stmt.GetContext(ctx, &user, 1)
+		assert.Equal(t, "sql: no rows in result set", err.Error())
+
+		cache.release(entry, nil)
+		err = entry.stmt.GetContext(ctx, &user, 1)
+		assert.Equal(t, errors.New("sql: statement is closed"), err)
+
+		assert.Equal(t, []string{c.exec.plan.deleteQuery}, c.cachedQueries())
+	})
+}
@@ -0,0 +1,156 @@
+package dbc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// TableColumn is the metadata of a column read from the database
+type TableColumn struct {
+	Name string `db:"name"`
+
+	// DataType is the column type reported by the database,
+	// e.g. 'bigint unsigned' on MySQL, 'character varying' on Postgres, 'INTEGER' on SQLite
+	DataType string `db:"data_type"`
+
+	Nullable      bool `db:"nullable"`
+	HasDefault    bool `db:"has_default"`
+	PrimaryKey    bool `db:"primary_key"`
+	AutoIncrement bool `db:"auto_increment"`
+}
+
+const mysqlTableColumnsQuery = `
+SELECT
+    COLUMN_NAME AS name,
+    COLUMN_TYPE AS data_type,
+    IS_NULLABLE = 'YES' AS nullable,
+    (COLUMN_DEFAULT IS NOT NULL OR EXTRA LIKE '%auto_increment%' OR EXTRA LIKE '%GENERATED%') AS has_default,
+    COLUMN_KEY = 'PRI' AS primary_key,
+    EXTRA LIKE '%auto_increment%' AS auto_increment
+FROM information_schema.COLUMNS
+WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?
+ORDER BY ORDINAL_POSITION
+`
+
+const postgresTableColumnsQuery = `
+SELECT
+    c.column_name AS name,
+    c.data_type AS data_type,
+    c.is_nullable = 'YES' AS nullable,
+    (c.column_default IS NOT NULL OR c.is_identity = 'YES' OR c.is_generated = 'ALWAYS') AS has_default,
+    EXISTS (
+        SELECT 1
+        FROM information_schema.table_constraints tc
+        JOIN information_schema.key_column_usage k
+            ON k.constraint_schema = tc.constraint_schema AND k.constraint_name = tc.constraint_name
+        WHERE tc.constraint_type = 'PRIMARY KEY'
+            AND tc.table_schema = c.table_schema AND tc.table_name = c.table_name
+            AND k.column_name = c.column_name
+    ) AS primary_key,
+    (c.is_identity = 'YES' OR COALESCE(c.column_default LIKE 'nextval(%', FALSE)) AS auto_increment
+FROM information_schema.columns c
+WHERE c.table_schema = current_schema() AND c.table_name = ?
+ORDER BY c.ordinal_position
+`
+
+// sqliteTableColumnsQuery treats primary key columns as NOT NULL,
+// because PRAGMA table_info reports 'INTEGER PRIMARY KEY' columns as nullable
+const sqliteTableColumnsQuery = `
+SELECT
+    name,
+    type AS data_type,
+    ("notnull" = 0 AND pk = 0) AS nullable,
+    dflt_value IS NOT NULL AS has_default,
+    pk > 0 AS primary_key,
+    0 AS auto_increment
+FROM pragma_table_info(?)
+ORDER BY cid
+`
+
+// GetTableColumns returns the columns of the table in the current database / schema,
+// using information_schema on MySQL and Postgres and PRAGMA table_info on SQLite.
+// The result is empty if the table does not exist
+func GetTableColumns(
+	ctx context.Context, db Readonly, dialect DatabaseDialect, tableName string,
+) ([]TableColumn, error) {
+	var query string
+	switch dialect {
+	case DialectMysql:
+		query = mysqlTableColumnsQuery
+	case DialectPostgres:
+		query = postgresTableColumnsQuery
+	case DialectSqlite:
+		query = sqliteTableColumnsQuery
+	default:
+		return nil, fmt.Errorf("unsupported database dialect: %v", dialect)
+	}
+
+	var columns []TableColumn
+	if err := db.SelectContext(ctx, &columns, db.Rebind(query), tableName); err != nil {
+		return nil, err
+	}
+
+	if dialect == DialectSqlite {
+		markSqliteRowIDAlias(columns)
+	}
+	return columns, nil
+}
+
+// markSqliteRowIDAlias marks the column 'INTEGER PRIMARY KEY' as auto increment,
+// it is an alias of the rowid and is assigned automatically when inserting
+func markSqliteRowIDAlias(columns []TableColumn) {
+	keyIndex := -1
+	for index, col := range columns {
+		if !col.PrimaryKey {
+			continue
+		}
+		if keyIndex >= 0 {
+			return // composite primary key
+		}
+		keyIndex = index
+	}
+
+	if keyIndex >= 0 && strings.EqualFold(columns[keyIndex].DataType, "INTEGER") {
+		columns[keyIndex].AutoIncrement = true
+	}
+}
+
+const mysqlTableNamesQuery = `
+SELECT TABLE_NAME FROM information_schema.TABLES
+WHERE TABLE_SCHEMA = DATABASE() AND TABLE_TYPE = 'BASE TABLE'
+ORDER BY TABLE_NAME
+`
+
+const postgresTableNamesQuery = `
+SELECT table_name FROM information_schema.tables
+WHERE table_schema = current_schema() AND table_type = 'BASE TABLE'
+ORDER BY table_name
+`
+
+const sqliteTableNamesQuery = `
+SELECT name FROM sqlite_master
+WHERE type = 'table' AND name NOT LIKE 'sqlite_%'
+ORDER BY name
+`
+
+// GetTableNames returns the names of the tables in the current database / schema, in increasing order
+func GetTableNames(ctx context.Context, db Readonly, dialect DatabaseDialect) ([]string, error) {
+	var query string
+	switch dialect {
+	case DialectMysql:
+		query = mysqlTableNamesQuery
+	case DialectPostgres:
+		query = postgresTableNamesQuery
+	case DialectSqlite:
+		query = sqliteTableNamesQuery
+	default:
+		return nil, fmt.Errorf("unsupported database dialect: %v", dialect)
+	}
+
+	var names []string
+	if err := db.SelectContext(ctx, &names, query); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
@@ -0,0 +1,56 @@
+package dbc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetTableColumns__SQLite(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	columns, err := GetTableColumns(ctx, db, DialectSqlite, "auth_user")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []TableColumn{
+		{Name: "id", DataType: "INTEGER", PrimaryKey: true, AutoIncrement: true},
+		{Name: "username", DataType: "TEXT"},
+		{Name: "created_at", DataType: "INTEGER"},
+	}, columns)
+
+	t.Run("composite primary key", func(t *testing.T) {
+		execTestQuery(t, db, `
+CREATE TABLE user_role (
+    user_id INTEGER NOT NULL,
+    role_id INTEGER NOT NULL,
+    note TEXT DEFAULT '',
+    PRIMARY KEY (user_id, role_id)
+)`)
+
+		columns, err := GetTableColumns(ctx, db, DialectSqlite, "user_role")
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []TableColumn{
+			{Name: "user_id", DataType: "INTEGER", PrimaryKey: true},
+			{Name: "role_id", DataType: "INTEGER", PrimaryKey: true},
+			{Name: "note", DataType: "TEXT", Nullable: true, HasDefault: true},
+		}, columns)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		columns, err := GetTableColumns(ctx, db, DialectSqlite, "not_found")
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []TableColumn(nil), columns)
+	})
+}
+
+func TestGetTableNames__SQLite(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	names, err := GetTableNames(ctx, db, DialectSqlite)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{
+		"auth_user", "schema_migration", "schema_migration_history", "schema_migration_lock",
+	}, names)
+}
@@ -70,3 +70,33 @@ type tableTest05 struct {
 func (tableTest05) TableName() string {
 	return "table_test05"
 }
+
+// ------------------------------
+
+type testTimestamps struct {
+	CreatedAt int64 `db:"created_at"`
+}
+
+type authUserInfo struct {
+	Username string `db:"username"`
+	testTimestamps
+}
+
+// authUserEmbedded is the table auth_user with the fields in nested embedded structs
+type authUserEmbedded struct {
+	ID int64 `db:"id"`
+	authUserInfo
+}
+
+func (authUserEmbedded) TableName() string {
+	return "auth_user"
+}
+
+type tableTest06 struct {
+	ID int64 `db:"id"`
+	*testTimestamps
+}
+
+func (tableTest06) TableName() string {
+	return "table_test06"
+}
@@ -0,0 +1,58 @@
+package dbc
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// UpdateBuilder collects the columns to be set by Executor.UpdateCond
+type UpdateBuilder[T TableNamer] struct {
+	basePtr unsafe.Pointer
+	schema  *Schema[T]
+
+	dialect DatabaseDialect
+
+	setList []string
+	args    []any
+	err     error // the first invalid field
+}
+
+type UpdateBuilderFunc[T TableNamer] = func(b *UpdateBuilder[T], table *T)
+
+func newUpdateBuilder[T TableNamer](schema *Schema[T], dialect DatabaseDialect) (*UpdateBuilder[T], *T) {
+	var emptyVal T
+	tablePtr := &emptyVal
+
+	return &UpdateBuilder[T]{
+		basePtr: unsafe.Pointer(tablePtr),
+		schema:  schema,
+		dialect: dialect,
+	}, tablePtr
+}
+
+// UpdateSet sets the column of the field to the value. Only the fields of SchemaEditable can be set,
+// the field of SchemaUpdatedAt is set automatically by Executor.UpdateCond
+func UpdateSet[T TableNamer, F any](b *UpdateBuilder[T], field *F, value F) {
+	if b.err != nil {
+		return
+	}
+
+	offset := unsafePointerSub(unsafe.Pointer(field), b.basePtr)
+	info, ok := b.schema.fieldInfos[offset]
+	switch {
+	case !ok:
+		b.err = fmt.Errorf("field is not found in the schema of type '%s'", b.schema.typeName())
+		return
+	case info.autoTime == autoTimeUpdatedAt:
+		b.err = fmt.Errorf(
+			"column '%s' of type '%s' is set automatically", info.dbName, b.schema.typeName(),
+		)
+		return
+	case info.specType != fieldSpecEditable:
+		b.err = fmt.Errorf("column '%s' of type '%s' is not editable", info.dbName, b.schema.typeName())
+		return
+	}
+
+	b.setList = append(b.setList, quoteIdentWithDialect(b.dialect, info.dbName)+" = ?")
+	b.args = append(b.args, value)
+}